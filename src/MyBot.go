@@ -1,14 +1,20 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	//	"io/ioutil"
 	"os"
 	"rand"
+	"runtime"
 	"time"
 )
 
 const FoodScore = 1000000
+
+// Scores of food an enemy ant reaches as fast as mine or faster
+const ContestedFoodScore = FoodScore / 2
+const LostFoodScore = FoodScore / 10
 const VisitScore = 1000
 const NeverVisitedScore = 100000
 const NeverVisitedScore2 = 50000
@@ -16,15 +22,44 @@ const EnemyWithdrawalScore = 4000
 const EnemyHillScore = 10000000
 const MoveFromMyHillScore = 10000000
 
+// Score of a move found by the battle search, only the moves off my hills go first
+const BattleScore = MoveFromMyHillScore / 2
+
+// Scores of squares around the attacked enemy hill
+const StormScore = EnemyHillScore / 10
+const AssaultStageScore = 2 * NeverVisitedScore
+
+// Score of a defender post around my threatened hill
+const DefenseScore = EnemyHillScore / 2
+
+// Scores of a random step into a square enemy ants can attack
+const DangerScore = 10
+const ContestScore = 2
+
+// My ants standing still for this many turns are removed from the locator
+const BlockIdleTurns = 3
+
 const MaxFindNearCount = 30
 
 const MaxDistToTarget = 10
 
 const GridSize = 8
 
+// Explored squares are visited again when they have not been seen for this long
+const ExploreStaleTurns = 20
+
+// At most this many explore targets are planned on a turn
+const MaxExploreTargets = 50
+
 const XaosP = 0.25
 
-var big = make([]int16, 200*1000*1000)
+// Time kept for moving ants and sending orders, the other phases stop earlier
+const TurnReserveMs = 20
+
+var plannerName = flag.String("planner", "greedy", "worker assignment planner: greedy or matching")
+var locatorName = flag.String("locator", "fair", "distance locator: fair or landmark")
+var locatorWorkers = flag.Int("locator_workers", 1, "number of goroutines updating the fair locator")
+var backgroundLocator = flag.Bool("background_locator", true, "update the locator between turns; off with -rerun unless set")
 
 type MyBot struct {
 	p               Params
@@ -33,10 +68,23 @@ type MyBot struct {
 	locsByProv      LocListMap
 	locSet          LocSet
 	perf            *Timing
-	loc             *FairLocator
+	loc             DynamicLocator
+	bgLoc           *BackgroundLocator
+	blocker         BlockingLocator // nil if the locator can't remove cells
+	blocked         LocSet          // cells of my ants removed from the locator
+	idle            LocSet
 	pf              PathFinder
 	LocatorBudgetMs int
 	gridSet         *GridLocatedSet
+	deadline        *Deadline
+	danger          *Danger
+	battle          *Battle
+	planner         Planner
+	explorer        *Explorer
+	gathering       *Gathering
+	foodSet         LocSet
+	assault         *Assault
+	defense         *Defense
 }
 
 func (b *MyBot) Init(p Params) (err os.Error) {
@@ -46,11 +94,52 @@ func (b *MyBot) Init(p Params) (err os.Error) {
 	b.m = NewMap(b.t, p.ViewRadius2)
 	b.locsByProv = NewLocListMap(b.t.Size())
 	b.locSet = NewLocSet(b.t.Size())
-	b.loc = NewFairLocator(b.m, big)
+	if b.loc, err = NewLocator(*locatorName, b.m, b.t.Size()); err != nil {
+		return
+	}
+	if fl, ok := b.loc.(*FairLocator); ok && *locatorWorkers > 1 {
+		fl.Workers = *locatorWorkers
+		if runtime.GOMAXPROCS(0) < fl.Workers {
+			runtime.GOMAXPROCS(fl.Workers)
+		}
+	}
+	_, blocking := b.loc.(BlockingLocator)
+	if *backgroundLocator {
+		b.bgLoc = NewBackgroundLocator(b.loc)
+		b.loc = b.bgLoc
+	}
+	if blocking {
+		b.blocker = b.loc.(BlockingLocator)
+		b.blocked = NewLocSet(b.t.Size())
+		b.idle = NewLocSet(b.t.Size())
+	}
 	b.pf = NewPathFinder(b.t, b.m, b.loc)
 	b.LocatorBudgetMs = 100
 	b.gridSet = NewGridLocatedSet(b.t, b.m, b.loc, GridSize)
-	return nil
+	b.danger = NewDanger(b.m, p.AttackRadius2)
+	b.battle = NewBattle(b.m, p.AttackRadius2)
+	b.explorer = NewExplorer(b.m, GridSize)
+	b.gathering = NewGathering(b.m, b.loc, p.SpawnRadius2)
+	b.foodSet = NewLocSet(b.t.Size())
+	b.assault = NewAssault(b.m, b.loc, b.danger)
+	b.defense = NewDefense(b.m, b.loc)
+	b.m.StayOnHill = b.defense.HoldingPost
+	b.planner, err = NewPlanner(*plannerName, b.t.Size())
+	return
+}
+
+// WriteMap saves the discovered part of the map as a .map file.
+func (b *MyBot) WriteMap(fileName string) os.Error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gm := NewGameMapFromMap(b.m)
+	if err := gm.checkHills(); err != nil {
+		fmt.Fprintf(os.Stderr, "The map %s is not playable: %v\n", fileName, err)
+	}
+	return WriteGameMap(f, gm)
 }
 
 type GridLocatedSet struct {
@@ -142,19 +231,25 @@ func (s *GridLocatedSet) All() (res []Location) {
 
 func (b *MyBot) Plan() {
 	l := b.loc
-	p := NewGreedyPlanner(b.t.Size())
+	p := b.planner
 	var workers []Location
 	var targets []Location
 	var scores []int
 	var prev []Assignment
 
+	food := b.m.Mem.Food()
+	b.foodSet.Clear()
+	for _, loc := range food {
+		b.foodSet.Add(loc)
+	}
+
 	for _, ant := range b.m.MyLiveAnts {
 		workers = append(workers, ant.Loc(b.m.Turn()))
 		if ant.Path == nil {
 			continue
 		}
 		loc := ant.Loc(b.m.Turn())
-		if loc == ant.Target {
+		if loc == ant.Target || b.foodSet.Has(ant.Target) && b.gathering.InRange(loc, ant.Target) {
 			// The target is reached
 			ant.Path = nil
 			continue
@@ -171,17 +266,44 @@ func (b *MyBot) Plan() {
 		scores = append(scores, score)
 	}
 
-	for _, food := range b.m.Food() {
-		addTarget(food, FoodScore)
+	var enemy []Location
+	for _, known := range b.m.Mem.Enemy() {
+		enemy = append(enemy, known.Loc)
+	}
+	for _, loc := range food {
+		if b.danger.At(loc) > 0 && !b.danger.Winning(loc) {
+			// Don't send ants to die for food
+			continue
+		}
+		addTarget(loc, b.gathering.Score(loc, workers, enemy))
+	}
+	defenseTargets, defenseScores := b.defense.Targets()
+	for i, loc := range defenseTargets {
+		addTarget(loc, defenseScores[i])
+	}
+	assaultTargets, assaultScores := b.assault.Targets()
+	for i, loc := range assaultTargets {
+		addTarget(loc, assaultScores[i])
+	}
+	exploreTargets, exploreScores := b.explorer.Targets()
+	for i, loc := range exploreTargets {
+		addTarget(loc, exploreScores[i])
 	}
 
 	//	fmt.Fprintf(os.Stderr, "scores: %v\n", scores)
 	b.perf.Log("Prepare data for planner")
+	if !b.deadline.Has(TurnReserveMs) {
+		return
+	}
 
 	plan := p.Plan(l, prev, b.gridSet, targets, scores)
 	b.perf.Log("Planner")
 	fmt.Fprintf(os.Stderr, "plan = %v\n", plan)
 	for _, assign := range plan {
+		if !b.deadline.Has(TurnReserveMs) {
+			fmt.Fprintf(os.Stderr, "Finding paths is interrupted by the deadline\n")
+			break
+		}
 		ant := b.m.MyLiveAntAt(assign.Worker)
 		if ant == nil {
 			panic("ant == nil")
@@ -189,7 +311,21 @@ func (b *MyBot) Plan() {
 		if ant.Target == assign.Target {
 			continue
 		}
-		ant.Path = b.pf.Path(ant.Loc(b.m.Turn()), assign.Target)
+		loc := ant.Loc(b.m.Turn())
+		if b.foodSet.Has(assign.Target) {
+			// Food can't be entered, the ant goes to the closest square it's gathered from
+			dist, to := b.gathering.Dist(loc, assign.Target)
+			switch dist {
+			case NoPath:
+				continue
+			case 0:
+				ant.Path = nil
+			default:
+				ant.Path = b.pf.Path(loc, to)
+			}
+		} else {
+			ant.Path = b.pf.Path(loc, assign.Target)
+		}
 		fmt.Fprintf(os.Stderr, "path: %v\n", ant.Path)
 		//fmt.Fprintf(os.Stderr, "p2  : %v\n", p2)
 		ant.Target = assign.Target
@@ -200,6 +336,84 @@ func (b *MyBot) Plan() {
 	return
 }
 
+// updateBlocked removes the water first taken for land and my idle ants
+// or ants staying on my hill from the locator, and restores the ants which have left.
+// The ants are left as they are when the deadline comes.
+func (b *MyBot) updateBlocked() {
+	removed := append([]Location(nil), b.m.NewWater...)
+	if b.deadline.Has(TurnReserveMs) {
+		turn := b.m.Turn()
+		b.idle.Clear()
+		for _, ant := range b.m.MyLiveAnts {
+			loc := ant.Loc(turn)
+			if b.m.HasMyHillAt(loc) && b.m.StayOnHill(ant) || b.standing(ant) {
+				b.idle.Add(loc)
+				if !b.blocked.Has(loc) {
+					removed = append(removed, loc)
+				}
+			}
+		}
+		var restored []Location
+		for _, loc := range b.blocked.All() {
+			if !b.idle.Has(loc) {
+				restored = append(restored, loc)
+			}
+		}
+		b.blocked, b.idle = b.idle, b.blocked
+		b.blocker.Restore(restored...)
+	}
+	if len(removed) == 0 {
+		return
+	}
+	if err := b.blocker.Remove(removed...); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+}
+
+// standing reports whether my ant has not moved for BlockIdleTurns,
+// because it has had nothing to do or its moves have failed.
+func (b *MyBot) standing(ant *MyAnt) bool {
+	turn := b.m.Turn()
+	for k := 1; k <= BlockIdleTurns; k++ {
+		if !ant.HasLoc(turn-k) || ant.Loc(turn-k) != ant.Loc(turn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fight overrides paths of ants in contact with the enemy by the moves found by the battle search.
+func (b *MyBot) Fight() {
+	turn := b.m.Turn()
+	for _, cl := range b.battle.Clusters() {
+		if !b.deadline.Has(TurnReserveMs) {
+			fmt.Fprintf(os.Stderr, "Battle search is interrupted by the deadline\n")
+			break
+		}
+		moves := b.battle.Search(cl, b.deadline)
+		for i, ant := range cl.Mine {
+			loc := ant.Loc(turn)
+			to := moves[i]
+			// Other ants must not take the square
+			ant.Score = BattleScore
+			if ant.Path != nil && ant.Path.Len() > 0 && b.t.NewLoc(loc, ant.Path.Dir(0)) == to {
+				// The planned move is good enough
+				continue
+			}
+			if to == loc {
+				ant.Path = nil
+				ant.Hold = true
+			} else {
+				path := NewPath(b.t, loc)
+				path.Append(b.t.GuessDir(loc, to))
+				ant.Path = path
+			}
+			ant.Target = to
+		}
+	}
+	b.perf.Log("Battle")
+}
+
 type Timing struct {
 	start int64
 	last  int64
@@ -285,25 +499,48 @@ func (b *MyBot) CheckMax(hill, loc Location, max int) bool {
 	//	panic("CheckMax not implemented")
 }
 
-func (b *MyBot) DoTurn(input []Input) (orders []Order, err os.Error) {
+func (b *MyBot) DoTurn(turn int, input []Input, deadline *Deadline) (orders []Order, err os.Error) {
 	b.perf = NewTiming()
+	b.deadline = deadline
+	if b.bgLoc != nil {
+		b.bgLoc.Pause()
+		b.perf.Log("Locator pause")
+		// The locator works while we wait for the next turn
+		defer b.bgLoc.Resume()
+	}
 	b.m.Update(input)
 	b.perf.Log("Map update")
+	if b.m.Turn() != turn {
+		fmt.Fprintf(os.Stderr, "Turn mismatch: the engine turn is %d, but the map turn is %d\n", turn, b.m.Turn())
+	}
 
 	fmt.Fprintf(os.Stderr, "len(NewCells): %d\n", len(b.m.NewCells))
-	b.loc.Add(b.m.NewCells...)
+	if err := b.loc.Add(b.m.NewCells...); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+	if b.blocker != nil {
+		b.updateBlocked()
+	}
 	b.loc.Update(func() bool {
-		return b.perf.CurMs() < b.LocatorBudgetMs
+		return b.perf.CurMs() < b.LocatorBudgetMs && b.deadline.Has(TurnReserveMs)
 	})
-	b.perf.Log("Fair locator update")
+	b.perf.Log("Locator update")
 
 	b.gridSet.Update()
 	b.perf.Log("GridLocatedSet update")
 
+	b.danger.Update()
+	b.perf.Log("Danger update")
+
 	//	b.Plan()
 
-	turn := b.m.Turn()
+	turn = b.m.Turn()
 	for _, ant := range b.m.MyLiveAnts {
+		if !b.deadline.Has(TurnReserveMs) {
+			// The rest of ants keep their old paths
+			fmt.Fprintf(os.Stderr, "Random walk is interrupted by the deadline\n")
+			break
+		}
 		loc := ant.Loc(b.m.Turn())
 		var a []Direction
 		var s []int
@@ -329,6 +566,13 @@ func (b *MyBot) DoTurn(input []Input) (orders []Order, err os.Error) {
 						score--
 					}
 				}
+				if danger := b.danger.At(newLoc); danger > 0 {
+					if b.danger.Winning(newLoc) {
+						score += ContestScore
+					} else {
+						score -= DangerScore * danger
+					}
+				}
 
 				a = append(a, dir)
 				s = append(s, score)
@@ -348,9 +592,14 @@ func (b *MyBot) DoTurn(input []Input) (orders []Order, err os.Error) {
 		ant.Path = path
 		ant.Score = 1
 	}
-	b.Plan()
+	if b.deadline.Has(TurnReserveMs) {
+		b.Plan()
+	}
+	if b.deadline.Has(TurnReserveMs) {
+		b.Fight()
+	}
 
-	b.m.MoveAnts()
+	b.m.MoveAnts(b.deadline)
 	b.perf.Log("MoveAnts")
 
 	for _, ant := range b.m.MyLiveAnts {
@@ -370,3 +619,25 @@ func (b *MyBot) DoTurn(input []Input) (orders []Order, err os.Error) {
 	b.perf.Total()
 	return
 }
+
+func (b *MyBot) Resync() {
+	if b.bgLoc != nil {
+		b.bgLoc.Pause()
+	}
+	b.m.Resync()
+}
+
+func (b *MyBot) End(result *GameResult) {
+	if b.bgLoc != nil {
+		b.bgLoc.Pause()
+	}
+	died := 0
+	for _, ant := range b.m.MyAnts {
+		if !ant.Alive {
+			died++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Game over at turn %d, players: %d, scores: %v\n", result.Turn, result.Players, result.Scores)
+	fmt.Fprintf(os.Stderr, "Ants born: %d, died: %d, alive: %d, hills: %d\n",
+		len(b.m.MyAnts), died, len(b.m.MyLiveAnts), len(b.m.MyHills()))
+}
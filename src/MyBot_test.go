@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMyBotBlocksStandingAnts(t *testing.T) {
+	defer func(bg bool) { *backgroundLocator = bg }(*backgroundLocator)
+	*backgroundLocator = false
+	b := new(MyBot)
+	err := b.Init(Params{
+		LoadTime:      3000,
+		TurnTime:      1000,
+		Players:       2,
+		Rows:          20,
+		Cols:          20,
+		Turns:         100,
+		ViewRadius2:   55,
+		AttackRadius2: 5,
+		SpawnRadius2:  1,
+		PlayerSeed:    1,
+	})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	fl := b.loc.(*FairLocator)
+	loc := b.t.Loc(5, 5)
+	// The moves of the ant fail
+	input := []Input{{What: Ant, Row: 5, Col: 5, Owner: Me}}
+	for turn := 1; turn <= BlockIdleTurns+1; turn++ {
+		if _, err := b.DoTurn(turn, input, NewDeadline(1000)); err != nil {
+			t.Fatalf("Turn %d: DoTurn: %v", turn, err)
+		}
+		removed := fl.blocked != nil && fl.blocked[loc]
+		if want := turn > BlockIdleTurns; removed != want {
+			t.Errorf("Turn %d: the square of the standing ant is removed: %v, want: %v", turn, removed, want)
+		}
+	}
+	// The ant has left
+	input[0].Col = 6
+	if _, err := b.DoTurn(BlockIdleTurns+2, input, NewDeadline(1000)); err != nil {
+		t.Fatalf("DoTurn: %v", err)
+	}
+	if fl.blocked[loc] {
+		t.Errorf("The square left by the ant is not restored")
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const LagMs int = 30
@@ -22,16 +25,78 @@ type Input struct {
 	Owner int
 }
 
+// GameResult is the end-of-game block sent by the engine.
+type GameResult struct {
+	Turn    int     // the last turn played
+	Players int     // number of players
+	Scores  []int   // scores by player, the bot itself is player 0
+	Input   []Input // the final state
+}
+
 type Bot interface {
 	Init(p Params) os.Error
-	DoTurn(input []Input) (orders []Order, err os.Error)
+	DoTurn(turn int, input []Input, deadline *Deadline) (orders []Order, err os.Error)
+	End(result *GameResult)
+}
+
+// Resyncer is implemented by bots which can rebuild their state
+// from the next turn's input after a failed turn.
+type Resyncer interface {
+	Resync()
+}
+
+// Deadline is the time by which the orders of the current turn must be sent.
+// A nil Deadline never expires.
+type Deadline struct {
+	start int64 // in nanoseconds
+	end   int64 // in nanoseconds
+}
+
+func NewDeadline(budgetMs int) *Deadline {
+	now := time.Nanoseconds()
+	return &Deadline{start: now, end: now + int64(budgetMs)*1000*1000}
+}
+
+// LeftMs returns the number of milliseconds left, it's negative if the deadline is missed.
+func (d *Deadline) LeftMs() int {
+	if d == nil {
+		return (1 << 31) - 1
+	}
+	return int((d.end - time.Nanoseconds()) / (1000 * 1000))
+}
+
+// ElapsedMs returns the number of milliseconds since the turn has been started.
+func (d *Deadline) ElapsedMs() int {
+	if d == nil {
+		return 0
+	}
+	return int((time.Nanoseconds() - d.start) / (1000 * 1000))
+}
+
+// Has reports whether more than ms milliseconds are left.
+func (d *Deadline) Has(ms int) bool {
+	return d.LeftMs() > ms
+}
+
+// Conn is a connection to the game engine.
+type Conn struct {
+	r *bufio.Reader
+	w io.Writer
+
+	// Replay records the game if not nil
+	Replay *Replay
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{r: bufio.NewReader(r), w: w}
 }
 
-var stdin = bufio.NewReader(os.Stdin)
+var stdConn = NewConn(os.Stdin, os.Stdout)
 
 type Params struct {
 	LoadTime      int   //in milliseconds
 	TurnTime      int   //in milliseconds
+	Players       int   //number of players
 	Rows          int   //number of rows in the map
 	Cols          int   //number of columns in the map
 	Turns         int   //maximum number of turns in the game
@@ -42,8 +107,12 @@ type Params struct {
 }
 
 func ReadParams() (p Params, err os.Error) {
+	return stdConn.ReadParams()
+}
+
+func (c *Conn) ReadParams() (p Params, err os.Error) {
 	for {
-		line, err := stdin.ReadString('\n')
+		line, err := c.r.ReadString('\n')
 		if err != nil {
 			return p, err
 		}
@@ -53,67 +122,153 @@ func ReadParams() (p Params, err os.Error) {
 			continue
 		}
 
+		if c.Replay != nil {
+			c.Replay.AddSetup(line)
+		}
+
 		if line == "ready" {
 			break
 		}
 
-		words := strings.SplitN(line, " ", 2)
+		if err = parseParam(&p, line); err != nil {
+			return p, err
+		}
+	}
+	return
+}
+
+// parseParam applies a single setup line like "rows 64" to p.
+func parseParam(p *Params, line string) os.Error {
+	words := strings.SplitN(line, " ", 2)
+	if len(words) != 2 {
+		return fmt.Errorf("Invalid command format: %s", line)
+	}
+
+	param, _ := strconv.Atoi(words[1])
+
+	switch words[0] {
+	case "loadtime":
+		p.LoadTime = param
+	case "turntime":
+		p.TurnTime = param
+	case "players":
+		p.Players = param
+	case "rows":
+		p.Rows = param
+	case "cols":
+		p.Cols = param
+	case "turns":
+		p.Turns = param
+	case "viewradius2":
+		p.ViewRadius2 = param
+	case "attackradius2":
+		p.AttackRadius2 = param
+	case "spawnradius2":
+		p.SpawnRadius2 = param
+	case "player_seed":
+		param64, _ := strconv.Atoi64(words[1])
+		p.PlayerSeed = param64
+	case "turn":
+	default:
+		// Newer engines can send more parameters
+		fmt.Fprintf(os.Stderr, "Unknown parameter is ignored: %s\n", line)
+	}
+	return nil
+}
+
+// parseInput parses a single turn line like "a 10 12 1".
+func parseInput(line string) (in Input, err os.Error) {
+	words := strings.SplitN(line, " ", 5)
+	if len(words) < 3 {
+		return in, fmt.Errorf("Invalid command format: \"%s\"", line)
+	}
+	in.What = int(words[0][0])
+	in.Row, _ = strconv.Atoi(words[1])
+	in.Col, _ = strconv.Atoi(words[2])
+	if in.What == Hill || in.What == Ant || in.What == DeadAnt {
+		if len(words) < 4 {
+			return in, fmt.Errorf("Owner is missing: \"%s\"", line)
+		}
+		in.Owner, _ = strconv.Atoi(words[3])
+	}
+	return
+}
+
+// parseLine applies a single line of the end-of-game block to r.
+func (r *GameResult) parseLine(line string) os.Error {
+	words := strings.Fields(line)
+	switch words[0] {
+	case "players":
 		if len(words) != 2 {
-			return p, fmt.Errorf("Invalid command format: %s", line)
-		}
-
-		param, _ := strconv.Atoi(words[1])
-
-		switch words[0] {
-		case "loadtime":
-			p.LoadTime = param
-		case "turntime":
-			p.TurnTime = param
-		case "rows":
-			p.Rows = param
-		case "cols":
-			p.Cols = param
-		case "turns":
-			p.Turns = param
-		case "viewradius2":
-			p.ViewRadius2 = param
-		case "attackradius2":
-			p.AttackRadius2 = param
-		case "spawnradius2":
-			p.SpawnRadius2 = param
-		case "player_seed":
-			param64, _ := strconv.Atoi64(words[1])
-			p.PlayerSeed = param64
-		case "turn":
-		case "ready":
-			return
-		default:
-			return p, fmt.Errorf("unknown command: %s", line)
+			return fmt.Errorf("Invalid command format: %s", line)
+		}
+		r.Players, _ = strconv.Atoi(words[1])
+	case "score":
+		r.Scores = r.Scores[:0]
+		for _, word := range words[1:] {
+			score, _ := strconv.Atoi(word)
+			r.Scores = append(r.Scores, score)
 		}
+	default:
+		in, err := parseInput(line)
+		if err != nil {
+			return err
+		}
+		r.Input = append(r.Input, in)
+	}
+	return nil
+}
+
+func formatOrder(order Order) string {
+	return fmt.Sprintf("o %d %d %c", order.Row, order.Col, order.Dir)
+}
+
+// recoverError turns a panic into an error with the stack trace.
+// It must be deferred.
+func recoverError(err *os.Error) {
+	if e := recover(); e != nil {
+		stack := make([]byte, 64*1024)
+		stack = stack[:runtime.Stack(stack, false)]
+		*err = fmt.Errorf("panic: %v\n%s", e, stack)
 	}
+}
+
+func safeDoTurn(b Bot, turn int, input []Input, deadline *Deadline) (orders []Order, err os.Error) {
+	defer recoverError(&err)
+	return b.DoTurn(turn, input, deadline)
+}
+
+func safeEnd(b Bot, result *GameResult) (err os.Error) {
+	defer recoverError(&err)
+	b.End(result)
 	return
 }
 
-func doTurn(p Params, b Bot, input []Input) (err os.Error) {
-	var orders []Order
-	if orders, err = b.DoTurn(input); err != nil {
-		return
+func (c *Conn) doTurn(p Params, b Bot, turn int, input []Input, deadline *Deadline) (orders []Order, err os.Error) {
+	if orders, err = safeDoTurn(b, turn, input, deadline); err != nil {
+		return nil, err
 	}
 	for _, order := range orders {
-		line := fmt.Sprintf("o %d %d %c\n", order.Row, order.Col, order.Dir)
-		os.Stdout.Write([]byte(line))
+		c.w.Write([]byte(formatOrder(order) + "\n"))
 	}
 	return
 }
 
 func Loop(p Params, b Bot) (err os.Error) {
+	return stdConn.Loop(p, b)
+}
+
+func (c *Conn) Loop(p Params, b Bot) (err os.Error) {
 	//indicate we're ready
-	os.Stdout.Write([]byte("go\n"))
+	c.w.Write([]byte("go\n"))
 
 	var input []Input
+	var lines []string
+	turn := 0
+	hasTurn := false
 	isNewTurn := true
 	for {
-		line, err := stdin.ReadString('\n')
+		line, err := c.r.ReadString('\n')
 		if err != nil {
 			if err == os.EOF && isNewTurn {
 				return nil
@@ -126,35 +281,89 @@ func Loop(p Params, b Bot) (err os.Error) {
 		}
 
 		if line == "go" {
-			if err = doTurn(p, b, input); err != nil {
-				return fmt.Errorf("doTurn: %v", err)
+			if !hasTurn {
+				// The engine has not sent the turn number, assume the next one
+				turn++
+			}
+			var deadline *Deadline
+			if p.TurnTime > 0 {
+				deadline = NewDeadline(p.TurnTime - LagMs)
+			}
+			start := time.Nanoseconds()
+			orders, err := c.doTurn(p, b, turn, input, deadline)
+			if err != nil {
+				// Send no orders, but keep playing
+				fmt.Fprintf(os.Stderr, "Turn %d failed: %v\nInput:\n%s\n", turn, err, strings.Join(lines, "\n"))
+				if r, ok := b.(Resyncer); ok {
+					r.Resync()
+				}
+			}
+			c.w.Write([]byte("go\n"))
+			if !deadline.Has(0) {
+				fmt.Fprintf(os.Stderr, "Turn %d: the deadline is missed by %d ms\n", turn, -deadline.LeftMs())
+			}
+			if c.Replay != nil {
+				var orderLines []string
+				for _, order := range orders {
+					orderLines = append(orderLines, formatOrder(order))
+				}
+				c.Replay.AddTurn(turn, lines, orderLines, int((time.Nanoseconds()-start)/(1000*1000)), err)
 			}
-			os.Stdout.Write([]byte("go\n"))
 			input = nil
+			lines = nil
+			hasTurn = false
 			isNewTurn = true
 			continue
 		}
 
 		if line == "end" {
-			break
+			return c.readEnd(b, turn)
 		}
 
-		words := strings.SplitN(line, " ", 5)
-		if words[0] == "turn" {
+		lines = append(lines, line)
+		if strings.HasPrefix(line, "turn ") {
+			turn, _ = strconv.Atoi(line[len("turn "):])
+			hasTurn = true
 			continue
 		}
-		if len(words) < 3 {
-			return fmt.Errorf("Invalid command format: \"%s\"", line)
-		}
-		var in Input
-		in.What = int(words[0][0])
-		in.Row, _ = strconv.Atoi(words[1])
-		in.Col, _ = strconv.Atoi(words[2])
-		if in.What == Hill || in.What == Ant || in.What == DeadAnt {
-			in.Owner, _ = strconv.Atoi(words[3])
+		in, err := parseInput(line)
+		if err != nil {
+			return err
 		}
 		input = append(input, in)
 	}
 
 	return nil
 }
+
+// readEnd reads the end-of-game block up to "go" and passes the result to the bot.
+func (c *Conn) readEnd(b Bot, turn int) os.Error {
+	res := &GameResult{Turn: turn}
+	var lines []string
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil && err != os.EOF {
+			return fmt.Errorf("ReadString: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "go" {
+			break
+		}
+		if line != "" {
+			lines = append(lines, line)
+			if err := res.parseLine(line); err != nil {
+				fmt.Fprintf(os.Stderr, "End of game: %v\n", err)
+			}
+		}
+		if err == os.EOF {
+			break
+		}
+	}
+	if c.Replay != nil {
+		c.Replay.SetEnd(lines)
+	}
+	if err := safeEnd(b, res); err != nil {
+		fmt.Fprintf(os.Stderr, "End of game: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+const loopTestStream = `turn 0
+loadtime 3000
+turntime 1000
+rows 10
+cols 10
+turns 3
+viewradius2 55
+attackradius2 5
+spawnradius2 1
+player_seed 42
+ready
+turn 1
+a 5 5 0
+go
+turn 2
+a 5 6 0
+go
+turn 3
+a 5 7 0
+go
+`
+
+// panicBot moves its ant east, but fails on the second turn.
+type panicBot struct {
+	resyncs int
+}
+
+func (b *panicBot) Init(p Params) os.Error {
+	return nil
+}
+
+func (b *panicBot) DoTurn(turn int, input []Input, deadline *Deadline) (orders []Order, err os.Error) {
+	if turn == 2 {
+		panic("panicBot fails")
+	}
+	return []Order{{Row: input[0].Row, Col: input[0].Col, Dir: East}}, nil
+}
+
+func (b *panicBot) End(result *GameResult) {
+}
+
+func (b *panicBot) Resync() {
+	b.resyncs++
+}
+
+func TestLoopRecovers(t *testing.T) {
+	var out bytes.Buffer
+	c := NewConn(strings.NewReader(loopTestStream), &out)
+	c.Replay = NewReplay()
+	p, err := c.ReadParams()
+	if err != nil {
+		t.Fatalf("ReadParams: %v", err)
+	}
+	b := new(panicBot)
+	if err = c.Loop(p, b); err != nil {
+		t.Fatalf("Loop: %v", err)
+	}
+	want := "go\no 5 5 E\ngo\ngo\no 5 7 E\ngo\n"
+	if out.String() != want {
+		t.Errorf("Output: %q, want: %q", out.String(), want)
+	}
+	if b.resyncs != 1 {
+		t.Errorf("Resync has been called %d times, want: 1", b.resyncs)
+	}
+	turns := c.Replay.ReplayData.BotTurns
+	if len(turns) != 3 || turns[1].Error == "" || turns[2].Error != "" {
+		t.Errorf("Unexpected turns in the replay: %+v", turns)
+	}
+}
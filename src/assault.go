@@ -0,0 +1,128 @@
+package main
+
+// My ants within this distance to the attacked hill take part in the assault
+const AssaultDist = 15
+
+// The hill is stormed by at least this number of ants,
+// and by this number of ants per enemy ant near the hill
+const MinAssaultAnts = 6
+const AssaultRatio = 2
+
+// Ants gather in the ring around the hill before the storm,
+// staying this far from each other
+const StageMinDist2 = 25
+const StageMaxDist2 = 64
+const StageSpacing2 = 4
+
+// Ants following the first one to the hill when it's stormed
+const StormDist2 = 10
+
+// Assault chooses an enemy hill and gives targets to capture it.
+// Ants gather around the hill out of the enemy reach, and when there are
+// enough of them, they go onto the hill.
+type Assault struct {
+	m        *Map
+	l        Locator
+	danger   *Danger
+	Target   *MemItem // the hill under attack
+	stageRow []int
+	stageCol []int
+	stormRow []int
+	stormCol []int
+}
+
+func NewAssault(m *Map, l Locator, danger *Danger) *Assault {
+	a := &Assault{m: m, l: l, danger: danger}
+	a.stageRow, a.stageCol = GenerateMask(StageMaxDist2)
+	a.stormRow, a.stormCol = GenerateMask(StormDist2)
+	return a
+}
+
+// choose keeps the current hill while it's not razed, otherwise takes the closest one.
+func (a *Assault) choose() {
+	hills := a.m.Mem.EnemyHills()
+	for _, hill := range hills {
+		if hill == a.Target {
+			return
+		}
+	}
+	a.Target = nil
+	best := NoPath
+	for _, hill := range hills {
+		for _, ant := range a.m.MyLiveAnts {
+			if d := a.l.Dist(ant.Loc(a.m.Turn()), hill.Loc); d < best {
+				best = d
+				a.Target = hill
+			}
+		}
+	}
+}
+
+// ring returns up to n land squares at the offsets from the hill,
+// not closer than StageSpacing2 to each other.
+func (a *Assault) ring(hill Location, maskRow, maskCol []int, minDist2, n int, ok func(Location) bool) (res []Location) {
+	for i := range maskRow {
+		if len(res) == n {
+			break
+		}
+		loc := a.m.T.ShiftLoc(hill, maskRow[i], maskCol[i])
+		if a.m.T.Dist2(hill, loc) <= minDist2 || a.m.Terrain[loc] != Land || !ok(loc) {
+			continue
+		}
+		spaced := true
+		for _, other := range res {
+			if a.m.T.Dist2(loc, other) < StageSpacing2 {
+				spaced = false
+				break
+			}
+		}
+		if spaced {
+			res = append(res, loc)
+		}
+	}
+	return
+}
+
+// Targets returns the hill and the squares around it when the hill is stormed,
+// or the staging squares while the ants are gathering.
+func (a *Assault) Targets() (targets []Location, scores []int) {
+	a.choose()
+	if a.Target == nil {
+		return
+	}
+	hill := a.Target.Loc
+	mine := 0
+	for _, ant := range a.m.MyLiveAnts {
+		if a.l.Dist(ant.Loc(a.m.Turn()), hill) <= AssaultDist {
+			mine++
+		}
+	}
+	enemy := 0
+	for _, known := range a.m.Mem.Enemy() {
+		if a.l.Dist(known.Loc, hill) <= AssaultDist {
+			enemy++
+		}
+	}
+	need := MinAssaultAnts
+	if n := AssaultRatio*enemy + 1; n > need {
+		need = n
+	}
+
+	if mine >= need {
+		targets = append(targets, hill)
+		scores = append(scores, EnemyHillScore)
+		for _, loc := range a.ring(hill, a.stormRow, a.stormCol, 0, need-1, func(Location) bool { return true }) {
+			targets = append(targets, loc)
+			scores = append(scores, StormScore)
+		}
+		return
+	}
+	safe := func(loc Location) bool {
+		return a.danger.At(loc) == 0 || a.danger.Winning(loc)
+	}
+	for _, loc := range a.ring(hill, a.stageRow, a.stageCol, StageMinDist2, need, safe) {
+		targets = append(targets, loc)
+		scores = append(scores, AssaultStageScore)
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAssault(t *testing.T) {
+	tt := Torus{40, 40}
+	m := NewMap(tt, 100)
+	update := func(ants int, hills ...Input) {
+		input := append([]Input{{What: Hill, Row: 20, Col: 20, Owner: Me}}, hills...)
+		for i := 0; i < ants; i++ {
+			input = append(input, Input{What: Ant, Row: 20, Col: 10 + i, Owner: Me})
+		}
+		m.Update(input)
+	}
+	hill1 := Input{What: Hill, Row: 20, Col: 5, Owner: 1}
+	hill2 := Input{What: Hill, Row: 5, Col: 30, Owner: 2}
+	l := manhattanLocator{tt}
+	a := NewAssault(m, l, NewDanger(m, 5))
+
+	// Too few ants: they gather around the closest hill
+	update(3, hill1, hill2)
+	hill := tt.Loc(20, 5)
+	targets, scores := a.Targets()
+	if a.Target == nil || a.Target.Loc != hill {
+		t.Fatalf("Target: %v, want: the hill at %d", a.Target, hill)
+	}
+	if len(targets) != MinAssaultAnts {
+		t.Errorf("Stage: %d targets, want: %d", len(targets), MinAssaultAnts)
+	}
+	for i, loc := range targets {
+		if d := tt.Dist2(loc, hill); d <= StageMinDist2 || d > StageMaxDist2 || scores[i] != AssaultStageScore {
+			t.Errorf("Stage: target %d at dist2 %d with score %d", loc, d, scores[i])
+		}
+	}
+
+	// Enough ants: storm
+	update(MinAssaultAnts, hill1)
+	targets, scores = a.Targets()
+	if len(targets) != MinAssaultAnts || targets[0] != hill || scores[0] != EnemyHillScore {
+		t.Errorf("Storm: targets: %v, scores: %v", targets, scores)
+	}
+
+	// The hill is razed: the next one is attacked
+	update(MinAssaultAnts)
+	a.Targets()
+	if a.Target == nil || a.Target.Loc != tt.Loc(5, 30) {
+		t.Errorf("Target after razing: %v, want: the hill at %d", a.Target, tt.Loc(5, 30))
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// BackgroundLocator updates a locator on a goroutine between turns,
+// while the bot waits for the input of the next turn.
+// The goroutine is stopped before any call is passed to the locator,
+// so the callers never see it in the middle of an update.
+type BackgroundLocator struct {
+	l       DynamicLocator
+	stop    chan bool
+	done    chan bool
+	running bool
+}
+
+func NewBackgroundLocator(l DynamicLocator) *BackgroundLocator {
+	return &BackgroundLocator{l: l}
+}
+
+// Resume starts updating the locator on a goroutine until Pause is called
+// or there is nothing to update.
+func (b *BackgroundLocator) Resume() {
+	if b.running {
+		return
+	}
+	b.running = true
+	b.stop = make(chan bool)
+	b.done = make(chan bool)
+	go func(stop, done chan bool) {
+		b.l.Update(func() bool {
+			// The goroutine may share the only thread with the bot,
+			// which must not wait for the whole update to wake up
+			runtime.Gosched()
+			select {
+			case <-stop:
+				return false
+			default:
+			}
+			return true
+		})
+		done <- true
+	}(b.stop, b.done)
+}
+
+// Pause stops the goroutine and waits for it.
+// The stop channel is closed, so every following check of the goroutine sees it.
+func (b *BackgroundLocator) Pause() {
+	if !b.running {
+		return
+	}
+	close(b.stop)
+	<-b.done
+	b.running = false
+}
+
+func (b *BackgroundLocator) Add(locs ...Location) os.Error {
+	b.Pause()
+	return b.l.Add(locs...)
+}
+
+// Remove passes the cells to the locator, it returns an error
+// if the locator is not a BlockingLocator.
+func (b *BackgroundLocator) Remove(locs ...Location) os.Error {
+	r, ok := b.l.(BlockingLocator)
+	if !ok {
+		return fmt.Errorf("BackgroundLocator: %T can't remove cells", b.l)
+	}
+	b.Pause()
+	return r.Remove(locs...)
+}
+
+func (b *BackgroundLocator) Restore(locs ...Location) {
+	if r, ok := b.l.(BlockingLocator); ok {
+		b.Pause()
+		r.Restore(locs...)
+	}
+}
+
+func (b *BackgroundLocator) Update(ok func() bool) {
+	b.Pause()
+	b.l.Update(ok)
+}
+
+func (b *BackgroundLocator) Dist(from, to Location) int {
+	b.Pause()
+	return b.l.Dist(from, to)
+}
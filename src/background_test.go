@@ -0,0 +1,67 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestBackgroundLocator(t *testing.T) {
+	for testInd, test := range []fairLocatorTest{
+		pseudoRandomTest(100, 1, 5),
+		pseudoRandomTest(200, 2, 2),
+	} {
+		want := NewFairLocator(&test, test.n)
+		fl := NewFairLocator(&test, test.n)
+		l := NewBackgroundLocator(fl)
+		for _, part := range [][]Location{test.run[0][:test.n/2], test.run[0][test.n/2:]} {
+			want.Add(part...)
+			want.Update(func() bool { return true })
+
+			// Cells are added while the goroutine is running
+			l.Add(part...)
+			l.Resume()
+			l.Resume()
+			runtime.Gosched()
+			l.Add(part...)
+			l.Resume()
+			for {
+				time.Sleep(1e6)
+				l.Pause()
+				if !fl.NeedUpdate() {
+					break
+				}
+				l.Resume()
+			}
+			for i := 0; i < test.n; i++ {
+				for j := 0; j < test.n; j++ {
+					if got := l.Dist(Location(i), Location(j)); got != want.Dist(Location(i), Location(j)) {
+						t.Fatalf("test #%d: Dist(%d, %d) = %d, want: %d", testInd, i, j, got, want.Dist(Location(i), Location(j)))
+					}
+				}
+			}
+		}
+		// Pause and Dist are fine when nothing is running
+		l.Pause()
+		l.Dist(0, 1)
+	}
+}
+
+// TestBackgroundLocatorPause pauses a long update, it must stop at once.
+func TestBackgroundLocatorPause(t *testing.T) {
+	m := landmarkTestMap(locatorBenchTorus, 25, 1)
+	l := NewBackgroundLocator(NewFairLocator(m, locatorBenchTorus.Size()))
+	l.Add(landmarkTestCells(m)...)
+	for i := 0; i < 5; i++ {
+		l.Resume()
+		time.Sleep(5e6)
+		start := time.Nanoseconds()
+		l.Pause()
+		if ms := (time.Nanoseconds() - start) / 1e6; ms > 50 {
+			t.Errorf("Pause #%d has taken %d ms", i, ms)
+		}
+	}
+	if !l.l.(*FairLocator).NeedUpdate() {
+		t.Errorf("The update has not been stopped")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"math"
+	"rand"
+)
+
+// Number of passes over ants of a cluster made by the battle search
+const MaxBattlePasses = 3
+
+// Number of random enemy moves the battle plan is checked against,
+// in addition to staying and approaching
+const BattleRandomResponses = 4
+
+// BattleCluster is a group of my and enemy ants which can fight on the next turn.
+type BattleCluster struct {
+	Mine  []*MyAnt
+	Enemy []Fighter
+}
+
+// Battle chooses moves of my ants in contact with the enemy.
+// Moves are improved one ant at a time, and every joint move is scored
+// by the combat simulation against a few possible moves of the enemy.
+// The worst outcome is taken, so the plan is pessimistic.
+type Battle struct {
+	m       *Map
+	c       *Combat
+	radius2 int
+}
+
+func NewBattle(m *Map, attackRadius2 int) *Battle {
+	// Ants of both sides can make one step towards each other
+	r := math.Sqrt(float64(attackRadius2)) + 2
+	return &Battle{
+		m:       m,
+		c:       NewCombat(m.T, attackRadius2),
+		radius2: int(r * r),
+	}
+}
+
+// Clusters returns groups of ants linked by the pairs of my and enemy ants
+// which can get into the attack radius of each other on the next turn.
+func (b *Battle) Clusters() (res []*BattleCluster) {
+	turn := b.m.Turn()
+	var enemy []Fighter
+	for _, item := range b.m.Items[turn].All {
+		if item.What == Ant && item.Owner != Me {
+			enemy = append(enemy, Fighter{Loc: item.Loc, Owner: item.Owner})
+		}
+	}
+	mine := b.m.MyLiveAnts
+	myDone := make([]bool, len(mine))
+	enemyDone := make([]bool, len(enemy))
+	for i := range mine {
+		if myDone[i] {
+			continue
+		}
+		cl := new(BattleCluster)
+		myDone[i] = true
+		q := []int{i}
+		for len(q) > 0 {
+			ant := mine[q[0]]
+			q = q[1:]
+			cl.Mine = append(cl.Mine, ant)
+			for j := range enemy {
+				if enemyDone[j] || b.m.T.Dist2(ant.Loc(turn), enemy[j].Loc) > b.radius2 {
+					continue
+				}
+				enemyDone[j] = true
+				cl.Enemy = append(cl.Enemy, enemy[j])
+				for k := range mine {
+					if !myDone[k] && b.m.T.Dist2(mine[k].Loc(turn), enemy[j].Loc) <= b.radius2 {
+						myDone[k] = true
+						q = append(q, k)
+					}
+				}
+			}
+		}
+		if len(cl.Enemy) > 0 {
+			res = append(res, cl)
+		}
+	}
+	return
+}
+
+// myMoves returns the locations my ant can be at on the next turn, staying first.
+// Moves refused by Map.CanMove are not included.
+func (b *Battle) myMoves(loc Location) []Location {
+	res := []Location{loc}
+	items := b.m.Items[b.m.Turn()]
+	for _, dir := range Dirs {
+		to := b.m.T.NewLoc(loc, dir)
+		if b.m.Terrain[to] != Water && items.CanEnter(to) {
+			res = append(res, to)
+		}
+	}
+	return res
+}
+
+// enemyMoves returns the locations the enemy ant can be at on the next turn, staying first.
+func (b *Battle) enemyMoves(loc Location) []Location {
+	res := []Location{loc}
+	for _, dir := range Dirs {
+		to := b.m.T.NewLoc(loc, dir)
+		if b.m.Terrain[to] != Water {
+			res = append(res, to)
+		}
+	}
+	return res
+}
+
+// responses returns joint enemy moves: all stay, all approach my ants, and a few random ones.
+func (b *Battle) responses(cl *BattleCluster) (res [][]Location) {
+	turn := b.m.Turn()
+	stay := make([]Location, len(cl.Enemy))
+	approach := make([]Location, len(cl.Enemy))
+	for j, enemy := range cl.Enemy {
+		stay[j] = enemy.Loc
+		best := -1
+		for _, to := range b.enemyMoves(enemy.Loc) {
+			for _, ant := range cl.Mine {
+				if d := b.m.T.Dist2(to, ant.Loc(turn)); best == -1 || d < best {
+					best = d
+					approach[j] = to
+				}
+			}
+		}
+	}
+	res = append(res, stay, approach)
+	for k := 0; k < BattleRandomResponses; k++ {
+		r := make([]Location, len(cl.Enemy))
+		for j, enemy := range cl.Enemy {
+			moves := b.enemyMoves(enemy.Loc)
+			r[j] = moves[rand.Intn(len(moves))]
+		}
+		res = append(res, r)
+	}
+	return
+}
+
+// Search returns the locations of my ants of the cluster on the next turn.
+// The search starts from the moves planned by ant paths and keeps them on ties.
+func (b *Battle) Search(cl *BattleCluster, deadline *Deadline) []Location {
+	turn := b.m.Turn()
+	n := len(cl.Mine)
+	moves := make([][]Location, n)
+	cur := make([]Location, n)
+	for i, ant := range cl.Mine {
+		loc := ant.Loc(turn)
+		moves[i] = b.myMoves(loc)
+		cur[i] = loc
+		if ant.Path != nil && ant.Path.Len() > 0 {
+			planned := b.m.T.NewLoc(loc, ant.Path.Dir(0))
+			for _, to := range moves[i] {
+				if to == planned {
+					cur[i] = planned
+				}
+			}
+		}
+	}
+	responses := b.responses(cl)
+	fighters := make([]Fighter, n+len(cl.Enemy))
+	eval := func() (score int) {
+		for k, r := range responses {
+			for i := range cur {
+				fighters[i] = Fighter{Loc: cur[i], Owner: Me}
+			}
+			for j := range r {
+				fighters[n+j] = Fighter{Loc: r[j], Owner: cl.Enemy[j].Owner}
+			}
+			mine, others := b.c.Losses(fighters, Me)
+			if k == 0 || others-mine < score {
+				score = others - mine
+			}
+		}
+		return
+	}
+
+	score := eval()
+	for pass := 0; pass < MaxBattlePasses; pass++ {
+		improved := false
+		for i := range cur {
+			if !deadline.Has(TurnReserveMs) {
+				return cur
+			}
+			best := cur[i]
+			for _, to := range moves[i] {
+				if to == best {
+					continue
+				}
+				cur[i] = to
+				if s := eval(); s > score {
+					score = s
+					best = to
+					improved = true
+				}
+			}
+			cur[i] = best
+		}
+		if !improved {
+			break
+		}
+	}
+	return cur
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBattleClusters(t *testing.T) {
+	tt := Torus{30, 30}
+	m := NewMap(tt, 4)
+	m.Update([]Input{
+		{What: Hill, Row: 20, Col: 20, Owner: Me},
+		// The first fight: a chain of my and enemy ants
+		{What: Ant, Row: 5, Col: 5, Owner: Me},
+		{What: Ant, Row: 5, Col: 9, Owner: 1},
+		{What: Ant, Row: 5, Col: 13, Owner: 1},
+		{What: Ant, Row: 5, Col: 12, Owner: Me},
+		// The second fight
+		{What: Ant, Row: 15, Col: 5, Owner: Me},
+		{What: Ant, Row: 16, Col: 6, Owner: 2},
+		// Nobody is near
+		{What: Ant, Row: 25, Col: 25, Owner: Me},
+		{What: Ant, Row: 25, Col: 15, Owner: 1},
+	})
+	b := NewBattle(m, 5)
+	cl := b.Clusters()
+	if len(cl) != 2 {
+		t.Fatalf("Clusters: %d, want: 2", len(cl))
+	}
+	if len(cl[0].Mine) != 2 || len(cl[0].Enemy) != 2 {
+		t.Errorf("The first cluster: my ants: %v, enemy: %v", cl[0].Mine, cl[0].Enemy)
+	}
+	if len(cl[1].Mine) != 1 || len(cl[1].Enemy) != 1 || cl[1].Enemy[0].Owner != 2 {
+		t.Errorf("The second cluster: my ants: %v, enemy: %v", cl[1].Mine, cl[1].Enemy)
+	}
+}
+
+func TestBattleSearch(t *testing.T) {
+	tt := Torus{20, 20}
+	m := NewMap(tt, 4)
+	m.Update([]Input{
+		{What: Hill, Row: 15, Col: 15, Owner: Me},
+		{What: Ant, Row: 5, Col: 5, Owner: Me},
+		{What: Ant, Row: 5, Col: 9, Owner: 1},
+		{What: Ant, Row: 6, Col: 9, Owner: 1},
+	})
+	ant := m.MyLiveAnts[0]
+	// Stepping east lets both enemy ants attack
+	ant.Path = NewPath(tt, tt.Loc(5, 5))
+	ant.Path.Append(East)
+	b := NewBattle(m, 5)
+	cl := b.Clusters()
+	if len(cl) != 1 {
+		t.Fatalf("Clusters: %d, want: 1", len(cl))
+	}
+	moves := b.Search(cl[0], nil)
+	if moves[0] == tt.Loc(5, 6) {
+		t.Errorf("The ant steps into the attack of two enemy ants")
+	}
+
+	// With no danger the planned move is kept
+	ant.Path = NewPath(tt, tt.Loc(5, 5))
+	ant.Path.Append(West)
+	moves = b.Search(cl[0], nil)
+	if moves[0] != tt.Loc(5, 4) {
+		t.Errorf("The planned move is changed: %d, want: %d", moves[0], tt.Loc(5, 4))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+// Fighter is an ant taking part in a battle.
+type Fighter struct {
+	Loc   Location
+	Owner int
+}
+
+// Combat resolves battles with the focus rule of the official engine:
+// an ant dies if there's an enemy in the attack radius which has
+// the same or less number of enemies in its attack radius.
+type Combat struct {
+	t       Torus
+	maskRow []int
+	maskCol []int
+	at      []int // index+1 of the fighter at the location
+	enemies []int
+}
+
+func NewCombat(t Torus, attackRadius2 int) *Combat {
+	c := &Combat{
+		t:  t,
+		at: make([]int, t.Size()),
+	}
+	c.maskRow, c.maskCol = GenerateMask(attackRadius2)
+	return c
+}
+
+// FightersFromItems returns all ants of the turn.
+func FightersFromItems(items *Items) (res []Fighter) {
+	for _, item := range items.All {
+		if item.What == Ant {
+			res = append(res, Fighter{Loc: item.Loc, Owner: item.Owner})
+		}
+	}
+	return
+}
+
+// Resolve returns which ants die. Ants sharing a location are considered
+// to collide, they all die and don't take part in the battle.
+func (c *Combat) Resolve(ants []Fighter) (dead []bool) {
+	dead = make([]bool, len(ants))
+	for i, ant := range ants {
+		if other := c.at[ant.Loc]; other > 0 {
+			dead[i] = true
+			dead[other-1] = true
+			continue
+		}
+		c.at[ant.Loc] = i + 1
+	}
+	// Collided ants must not fight
+	for i, ant := range ants {
+		if dead[i] {
+			c.at[ant.Loc] = 0
+		}
+	}
+
+	if cap(c.enemies) < len(ants) {
+		c.enemies = make([]int, len(ants))
+	}
+	c.enemies = c.enemies[:len(ants)]
+	for i, ant := range ants {
+		c.enemies[i] = 0
+		if dead[i] {
+			continue
+		}
+		for j := range c.maskRow {
+			other := c.at[c.t.ShiftLoc(ant.Loc, c.maskRow[j], c.maskCol[j])] - 1
+			if other >= 0 && ants[other].Owner != ant.Owner {
+				c.enemies[i]++
+			}
+		}
+	}
+	var killed []int
+	for i, ant := range ants {
+		if dead[i] || c.enemies[i] == 0 {
+			continue
+		}
+		for j := range c.maskRow {
+			other := c.at[c.t.ShiftLoc(ant.Loc, c.maskRow[j], c.maskCol[j])] - 1
+			if other >= 0 && ants[other].Owner != ant.Owner && c.enemies[other] <= c.enemies[i] {
+				killed = append(killed, i)
+				break
+			}
+		}
+	}
+	for _, i := range killed {
+		dead[i] = true
+	}
+	for _, ant := range ants {
+		c.at[ant.Loc] = 0
+	}
+	return
+}
+
+// Losses simulates the battle and counts dead ants of the owner and of all the others.
+// It can be used to score a hypothetical set of moves.
+func (c *Combat) Losses(ants []Fighter, owner int) (mine, others int) {
+	for i, d := range c.Resolve(ants) {
+		if !d {
+			continue
+		}
+		if ants[i].Owner == owner {
+			mine++
+		} else {
+			others++
+		}
+	}
+	return
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+)
+
+type combatTest struct {
+	Name string
+	Ants []engineAnt // Dir is ignored
+	Dead []bool
+}
+
+var combatTests = []combatTest{
+	{
+		Name: "one on one",
+		Ants: []engineAnt{{5, 5, 0, 0}, {5, 7, 1, 0}},
+		Dead: []bool{true, true},
+	},
+	{
+		Name: "out of range",
+		Ants: []engineAnt{{5, 5, 0, 0}, {6, 7, 1, 0}, {8, 5, 1, 0}},
+		Dead: []bool{true, true, false},
+	},
+	{
+		Name: "far away",
+		Ants: []engineAnt{{5, 5, 0, 0}, {7, 7, 1, 0}},
+		Dead: []bool{false, false},
+	},
+	{
+		Name: "two on one",
+		Ants: []engineAnt{{5, 5, 0, 0}, {5, 6, 0, 0}, {5, 7, 1, 0}},
+		Dead: []bool{false, false, true},
+	},
+	{
+		Name: "surrounded",
+		Ants: []engineAnt{{5, 5, 1, 0}, {4, 5, 0, 0}, {6, 5, 0, 0}, {5, 4, 0, 0}, {5, 6, 0, 0}},
+		Dead: []bool{true, false, false, false, false},
+	},
+	{
+		// a b a b in a row: the ends survive, the middle ants die
+		Name: "chain",
+		Ants: []engineAnt{{5, 0, 0, 0}, {5, 2, 1, 0}, {5, 4, 0, 0}, {5, 6, 1, 0}},
+		Dead: []bool{false, true, true, false},
+	},
+	{
+		Name: "three players",
+		Ants: []engineAnt{{5, 5, 0, 0}, {5, 6, 1, 0}, {6, 5, 2, 0}},
+		Dead: []bool{true, true, true},
+	},
+	{
+		// Both enemies of the middle ant have one enemy, the middle one has two
+		Name: "one between two",
+		Ants: []engineAnt{{5, 3, 1, 0}, {5, 5, 0, 0}, {5, 7, 1, 0}},
+		Dead: []bool{false, true, false},
+	},
+	{
+		// The ants of the same owner collide, the enemy has nobody to fight with
+		Name: "collision",
+		Ants: []engineAnt{{5, 5, 0, 0}, {5, 5, 0, 0}, {5, 6, 1, 0}},
+		Dead: []bool{true, true, false},
+	},
+	{
+		Name: "wrap around the torus",
+		Ants: []engineAnt{{0, 0, 0, 0}, {15, 15, 1, 0}},
+		Dead: []bool{true, true},
+	},
+}
+
+func TestCombat(t *testing.T) {
+	tt := Torus{Rows: 16, Cols: 16}
+	c := NewCombat(tt, 5)
+	for _, test := range combatTests {
+		var ants []Fighter
+		for _, a := range test.Ants {
+			ants = append(ants, Fighter{Loc: tt.Loc(a.Row, a.Col), Owner: a.Owner})
+		}
+		// Run twice to check that the state is cleaned up
+		for run := 0; run < 2; run++ {
+			dead := c.Resolve(ants)
+			for i := range dead {
+				if dead[i] != test.Dead[i] {
+					t.Errorf("%s, run #%d: ant #%d dead: %v, want: %v", test.Name, run, i, dead[i], test.Dead[i])
+				}
+			}
+		}
+	}
+}
+
+func TestCombatLosses(t *testing.T) {
+	tt := Torus{Rows: 16, Cols: 16}
+	c := NewCombat(tt, 5)
+	ants := []Fighter{{tt.Loc(5, 5), 0}, {tt.Loc(5, 6), 0}, {tt.Loc(5, 7), 1}, {tt.Loc(10, 10), 2}}
+	if mine, others := c.Losses(ants, 0); mine != 0 || others != 1 {
+		t.Errorf("Losses: %d, %d, want: 0, 1", mine, others)
+	}
+}
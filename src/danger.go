@@ -0,0 +1,71 @@
+package main
+
+// Danger counts ants which can attack each square on the next turn.
+// Every ant can stay or make one step to any square which is not water,
+// then it attacks all squares in the attack radius from there.
+type Danger struct {
+	m       *Map
+	maskRow []int
+	maskCol []int
+	Enemy   []int // number of enemy ants which can attack the square
+	Mine    []int // number of my ants which can attack the square
+	seen    LocSet
+}
+
+func NewDanger(m *Map, attackRadius2 int) *Danger {
+	d := &Danger{
+		m:     m,
+		Enemy: make([]int, m.T.Size()),
+		Mine:  make([]int, m.T.Size()),
+		seen:  NewLocSet(m.T.Size()),
+	}
+	d.maskRow, d.maskCol = GenerateMask(attackRadius2)
+	return d
+}
+
+// Update must be called after the map has been updated with the current turn.
+func (d *Danger) Update() {
+	for i := range d.Enemy {
+		d.Enemy[i] = 0
+		d.Mine[i] = 0
+	}
+	for _, loc := range d.m.Enemy() {
+		d.add(d.Enemy, loc)
+	}
+	for _, ant := range d.m.MyLiveAnts {
+		d.add(d.Mine, ant.Loc(d.m.Turn()))
+	}
+}
+
+// add counts the ant once for every square it can attack.
+func (d *Danger) add(count []int, loc Location) {
+	d.seen.Clear()
+	d.addFrom(count, loc)
+	for _, dir := range Dirs {
+		to := d.m.T.NewLoc(loc, dir)
+		if d.m.Terrain[to] != Water {
+			d.addFrom(count, to)
+		}
+	}
+}
+
+func (d *Danger) addFrom(count []int, loc Location) {
+	for i := range d.maskRow {
+		loc2 := d.m.T.ShiftLoc(loc, d.maskRow[i], d.maskCol[i])
+		if !d.seen.Has(loc2) {
+			d.seen.Add(loc2)
+			count[loc2]++
+		}
+	}
+}
+
+// At returns the number of enemy ants which can attack the square on the next turn.
+func (d *Danger) At(loc Location) int {
+	return d.Enemy[loc]
+}
+
+// Winning reports whether my ants which can attack the square outnumber the enemy ones.
+// It's a rough estimate: the focus rule depends on exact positions.
+func (d *Danger) Winning(loc Location) bool {
+	return d.Mine[loc] > d.Enemy[loc]
+}
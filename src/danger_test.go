@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDanger(t *testing.T) {
+	tt := Torus{16, 16}
+	m := NewMap(tt, 4)
+	m.Terrain[tt.Loc(5, 6)] = Water
+	m.Update([]Input{
+		{What: Hill, Row: 5, Col: 10, Owner: Me},
+		{What: Ant, Row: 5, Col: 10, Owner: Me},
+		{What: Ant, Row: 5, Col: 5, Owner: 1},
+	})
+	d := NewDanger(m, 5)
+	d.Update()
+	tests := []struct {
+		Row, Col int
+		Enemy    int
+		Winning  bool
+	}{
+		{5, 5, 1, false},
+		{5, 7, 1, false},
+		// The enemy can't step east because of the water
+		{5, 8, 0, true},
+		{5, 2, 1, false},
+		{5, 1, 0, false},
+		{2, 5, 1, false},
+		{1, 5, 0, false},
+		{5, 13, 0, true},
+		{5, 14, 0, false},
+	}
+	for i, test := range tests {
+		loc := tt.Loc(test.Row, test.Col)
+		if got := d.At(loc); got != test.Enemy {
+			t.Errorf("test #%d: At(%d, %d) = %d, want: %d", i, test.Row, test.Col, got, test.Enemy)
+		}
+		if got := d.Winning(loc); got != test.Winning {
+			t.Errorf("test #%d: Winning(%d, %d) = %v, want: %v", i, test.Row, test.Col, got, test.Winning)
+		}
+	}
+}
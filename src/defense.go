@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sort"
+)
+
+// Enemy ants within this distance to my hill threaten it
+const DefenseDist = 12
+
+// Defenders stand in the ring around the hill
+const DefenseMinDist2 = 2
+const DefenseMaxDist2 = 10
+
+// Not more than this share of my ants defend the hills
+const MaxDefenseShare = 0.5
+
+// Defense puts my ants around my hills when enemy ants approach.
+// One defender stands on the hill, and one more than the number of enemy ants
+// near the hill take the squares of the ring closest to the enemy.
+type Defense struct {
+	m       *Map
+	l       Locator
+	ringRow []int
+	ringCol []int
+	Posts   LocSet // squares taken by defenders on the current turn
+}
+
+func NewDefense(m *Map, l Locator) *Defense {
+	d := &Defense{m: m, l: l, Posts: NewLocSet(m.T.Size())}
+	d.ringRow, d.ringCol = GenerateMask(DefenseMaxDist2)
+	return d
+}
+
+// Threat returns the enemy ants close to the hill.
+func (d *Defense) Threat(hill Location) (res []Location) {
+	for _, loc := range d.m.Enemy() {
+		if dist := d.l.Dist(loc, hill); dist != NoPath && dist <= DefenseDist {
+			res = append(res, loc)
+		}
+	}
+	return
+}
+
+type defenseSorter struct {
+	locs []Location
+	dist []int
+}
+
+func (s *defenseSorter) Len() int {
+	return len(s.locs)
+}
+
+func (s *defenseSorter) Less(i, j int) bool {
+	return s.dist[i] < s.dist[j]
+}
+
+func (s *defenseSorter) Swap(i, j int) {
+	s.locs[i], s.locs[j] = s.locs[j], s.locs[i]
+	s.dist[i], s.dist[j] = s.dist[j], s.dist[i]
+}
+
+// posts returns n squares of the ring around the hill, the closest to the enemy first.
+func (d *Defense) posts(hill Location, enemy []Location, n int) []Location {
+	s := new(defenseSorter)
+	for i := range d.ringRow {
+		loc := d.m.T.ShiftLoc(hill, d.ringRow[i], d.ringCol[i])
+		if d.m.T.Dist2(hill, loc) <= DefenseMinDist2 || d.m.Terrain[loc] != Land {
+			continue
+		}
+		best := NoPath
+		for _, e := range enemy {
+			if dist := d.l.Dist(e, loc); dist < best {
+				best = dist
+			}
+		}
+		if best == NoPath {
+			continue
+		}
+		s.locs = append(s.locs, loc)
+		s.dist = append(s.dist, best)
+	}
+	sort.Sort(s)
+	if len(s.locs) > n {
+		return s.locs[:n]
+	}
+	return s.locs
+}
+
+// Targets returns the posts of defenders of all threatened hills.
+func (d *Defense) Targets() (targets []Location, scores []int) {
+	d.Posts.Clear()
+	left := int(float64(len(d.m.MyLiveAnts)) * MaxDefenseShare)
+	for _, hill := range d.m.MyHills() {
+		enemy := d.Threat(hill.Loc)
+		if len(enemy) == 0 {
+			continue
+		}
+		if left == 0 {
+			break
+		}
+		need := len(enemy) + 1
+		if need > left-1 {
+			need = left - 1
+		}
+		// An ant standing on the hill keeps enemy ants off it
+		for _, loc := range append([]Location{hill.Loc}, d.posts(hill.Loc, enemy, need)...) {
+			d.Posts.Add(loc)
+			targets = append(targets, loc)
+			scores = append(scores, DefenseScore)
+			left--
+		}
+	}
+	return
+}
+
+// Defending reports whether the ant is going to or standing at a post.
+func (d *Defense) Defending(ant *MyAnt) bool {
+	return d.Posts.Has(ant.Target)
+}
+
+// HoldingPost reports whether the ant stands at its post,
+// the ant holding the post on my hill may stay there, see Map.StayOnHill.
+func (d *Defense) HoldingPost(ant *MyAnt) bool {
+	return d.Defending(ant) && ant.Target == ant.Loc(d.m.Turn())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDefense(t *testing.T) {
+	tt := Torus{30, 30}
+	m := NewMap(tt, 100)
+	d := NewDefense(m, manhattanLocator{tt})
+	myAnts := func(n int) (res []Input) {
+		res = []Input{{What: Hill, Row: 10, Col: 10, Owner: Me}}
+		for i := 0; i < n; i++ {
+			res = append(res, Input{What: Ant, Row: 10 + i, Col: 9, Owner: Me})
+		}
+		return
+	}
+
+	m.Update(append(myAnts(6), Input{What: Ant, Row: 10, Col: 25, Owner: 1}))
+	if targets, _ := d.Targets(); len(targets) != 0 {
+		t.Errorf("No threat: targets: %v", targets)
+	}
+
+	// Two enemy ants approach from the east, one of my ants is on the hill
+	m.Update(append(myAnts(6),
+		Input{What: Ant, Row: 10, Col: 10, Owner: Me},
+		Input{What: Ant, Row: 10, Col: 17, Owner: 1},
+		Input{What: Ant, Row: 11, Col: 18, Owner: 1},
+	))
+	targets, scores := d.Targets()
+	hill := tt.Loc(10, 10)
+	if len(targets) != 3 || targets[0] != hill {
+		t.Fatalf("Targets: %v, want the hill and 2 posts", targets)
+	}
+	for i, loc := range targets[1:] {
+		dist2 := tt.Dist2(hill, loc)
+		if dist2 <= DefenseMinDist2 || dist2 > DefenseMaxDist2 || tt.Col(loc) <= 10 || scores[i] != DefenseScore {
+			t.Errorf("Post (%d, %d) with score %d", tt.Row(loc), tt.Col(loc), scores[i+1])
+		}
+		if !d.Posts.Has(loc) {
+			t.Errorf("Post (%d, %d) is not in Posts", tt.Row(loc), tt.Col(loc))
+		}
+	}
+
+	// The ant going to a post does not hold it yet
+	ant := m.MyLiveAntAt(tt.Loc(10, 9))
+	ant.Target = targets[0]
+	if !d.Defending(ant) || d.HoldingPost(ant) {
+		t.Errorf("The ant going to a post: Defending: %v, HoldingPost: %v",
+			d.Defending(ant), d.HoldingPost(ant))
+	}
+
+	// The ant on the hill holds the post there
+	onHill := m.MyLiveAntAt(hill)
+	onHill.Target = hill
+	if !d.HoldingPost(onHill) {
+		t.Errorf("The ant on the hill does not hold the post there")
+	}
+
+	// Not enough ants to defend with all of them
+	m.Update(append(myAnts(2),
+		Input{What: Ant, Row: 10, Col: 17, Owner: 1},
+		Input{What: Ant, Row: 11, Col: 18, Owner: 1},
+	))
+	if targets, _ := d.Targets(); len(targets) != 1 || targets[0] != hill {
+		t.Errorf("Targets with 2 ants: %v, want the hill", targets)
+	}
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"rand"
+	"strconv"
+	"strings"
+)
+
+const DefaultFoodPercent = 50
+const DefaultFoodStart = 2
+
+// Number of attempts to find a free land cell for a new food item
+const MaxFoodAttempts = 100
+
+// GameMap is a complete world: terrain of every cell and hills of every player.
+type GameMap struct {
+	T       Torus
+	Players int
+	Terrain []Terrain
+	Hills   [][]Location // hills by player
+}
+
+// GameOptions are the rules of a local game.
+// Params are sent to the bots as is, except for PlayerSeed,
+// which is used as the game seed and is derived for each player.
+type GameOptions struct {
+	Params
+	FoodPercent int // chance (in percent) to spawn a food item for each player per turn
+	FoodStart   int // food items per player spawned before the first turn
+}
+
+type gameAnt struct {
+	Loc   Location
+	Owner int
+	Dir   Direction // order for the current turn, 0 if none
+	Alive bool
+}
+
+type gameHill struct {
+	Loc   Location
+	Owner int
+	Razed bool
+}
+
+// Game implements the rules of the Ants game.
+type Game struct {
+	opts    GameOptions
+	t       Torus
+	players int
+	terrain []Terrain
+	rnd     *rand.Rand
+
+	turn  int // number of finished turns
+	ants  []*gameAnt
+	antAt []*gameAnt
+	dead  []*gameAnt // ants died during the last turn
+	food  []Location
+	hills []*gameHill
+	hive  []int // food gathered, but not spawned yet
+	score []int
+	out   []bool   // players that crashed or timed out
+	sent  [][]bool // water cells already sent to the player
+
+	viewRow, viewCol   []int
+	spawnRow, spawnCol []int
+	combat             *Combat
+}
+
+func NewGame(gm *GameMap, opts GameOptions) *Game {
+	t := gm.T
+	g := &Game{
+		opts:    opts,
+		t:       t,
+		players: gm.Players,
+		terrain: make([]Terrain, t.Size()),
+		rnd:     rand.New(rand.NewSource(opts.PlayerSeed)),
+		antAt:   make([]*gameAnt, t.Size()),
+		hive:    make([]int, gm.Players),
+		score:   make([]int, gm.Players),
+		out:     make([]bool, gm.Players),
+		sent:    make([][]bool, gm.Players),
+	}
+	g.opts.Rows = t.Rows
+	g.opts.Cols = t.Cols
+	copy(g.terrain, gm.Terrain)
+	for player, hills := range gm.Hills {
+		for _, loc := range hills {
+			g.hills = append(g.hills, &gameHill{Loc: loc, Owner: player})
+			// Each player starts with one ant and one point per hill
+			g.hive[player]++
+			g.score[player]++
+		}
+		g.sent[player] = make([]bool, t.Size())
+	}
+	g.viewRow, g.viewCol = GenerateMask(opts.ViewRadius2)
+	g.combat = NewCombat(t, opts.AttackRadius2)
+	g.spawnRow, g.spawnCol = GenerateMask(opts.SpawnRadius2)
+
+	g.doSpawn()
+	for i := 0; i < opts.FoodStart*g.players; i++ {
+		g.addRandomFood()
+	}
+	return g
+}
+
+func (g *Game) Turn() int {
+	return g.turn
+}
+
+func (g *Game) Scores() []int {
+	res := make([]int, len(g.score))
+	copy(res, g.score)
+	return res
+}
+
+// rel translates an owner id into the numbering seen by the player:
+// the player itself is always 0.
+func (g *Game) rel(player, owner int) int {
+	return (owner - player + g.players) % g.players
+}
+
+func (g *Game) hasFood(loc Location) bool {
+	for _, food := range g.food {
+		if food == loc {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) hasHill(loc Location) bool {
+	for _, hill := range g.hills {
+		if hill.Loc == loc {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) addRandomFood() {
+	for i := 0; i < MaxFoodAttempts; i++ {
+		loc := Location(g.rnd.Intn(g.t.Size()))
+		if g.terrain[loc] == Water || g.antAt[loc] != nil || g.hasFood(loc) || g.hasHill(loc) {
+			continue
+		}
+		g.food = append(g.food, loc)
+		return
+	}
+}
+
+// IsAlive reports whether the player still has ants or hills.
+func (g *Game) IsAlive(player int) bool {
+	for _, ant := range g.ants {
+		if ant.Owner == player {
+			return true
+		}
+	}
+	for _, hill := range g.hills {
+		if hill.Owner == player && !hill.Razed {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the player should be asked for orders.
+func (g *Game) Active(player int) bool {
+	return !g.out[player] && g.IsAlive(player)
+}
+
+// Drop excludes the player from the game. Its ants stay on the map, but never move.
+func (g *Game) Drop(player int, reason os.Error) {
+	fmt.Fprintf(os.Stderr, "turn %d: player %d is out: %v\n", g.turn, player, reason)
+	g.out[player] = true
+}
+
+func (g *Game) GameOver() bool {
+	if g.turn >= g.opts.Turns {
+		return true
+	}
+	alive := 0
+	for player := 0; player < g.players; player++ {
+		if g.IsAlive(player) {
+			alive++
+		}
+	}
+	return g.players > 1 && alive <= 1 || alive == 0
+}
+
+// finish awards the bonus to the last player standing:
+// its enemies' hills which have not been razed yet count as razed by it.
+func (g *Game) finish() {
+	winner := -1
+	for player := 0; player < g.players; player++ {
+		if g.IsAlive(player) {
+			if winner != -1 {
+				return
+			}
+			winner = player
+		}
+	}
+	if winner == -1 {
+		return
+	}
+	for _, hill := range g.hills {
+		if !hill.Razed && hill.Owner != winner {
+			g.score[winner] += 2
+			g.score[hill.Owner]--
+		}
+	}
+}
+
+func (g *Game) visible(player int) []bool {
+	res := make([]bool, g.t.Size())
+	for _, ant := range g.ants {
+		if ant.Owner != player {
+			continue
+		}
+		for i := range g.viewRow {
+			res[g.t.ShiftLoc(ant.Loc, g.viewRow[i], g.viewCol[i])] = true
+		}
+	}
+	return res
+}
+
+func (g *Game) formatLoc(what int, loc Location) string {
+	return fmt.Sprintf("%c %d %d", what, g.t.Row(loc), g.t.Col(loc))
+}
+
+// State returns the lines describing what the player sees now,
+// in the order used by the official engine.
+func (g *Game) State(player int) (res []string) {
+	vis := g.visible(player)
+	for loc, v := range vis {
+		if v && g.terrain[loc] == Water && !g.sent[player][loc] {
+			g.sent[player][loc] = true
+			res = append(res, g.formatLoc(Water, Location(loc)))
+		}
+	}
+	for _, loc := range g.food {
+		if vis[loc] {
+			res = append(res, g.formatLoc(Food, loc))
+		}
+	}
+	for _, hill := range g.hills {
+		if vis[hill.Loc] && !hill.Razed {
+			res = append(res, fmt.Sprintf("%s %d", g.formatLoc(Hill, hill.Loc), g.rel(player, hill.Owner)))
+		}
+	}
+	for _, ant := range g.ants {
+		if vis[ant.Loc] {
+			res = append(res, fmt.Sprintf("%s %d", g.formatLoc(Ant, ant.Loc), g.rel(player, ant.Owner)))
+		}
+	}
+	for _, ant := range g.dead {
+		if vis[ant.Loc] {
+			res = append(res, fmt.Sprintf("%s %d", g.formatLoc(DeadAnt, ant.Loc), g.rel(player, ant.Owner)))
+		}
+	}
+	return
+}
+
+func (g *Game) SetupLines(player int) []string {
+	p := g.opts.Params
+	return []string{
+		"turn 0",
+		fmt.Sprintf("players %d", g.players),
+		fmt.Sprintf("loadtime %d", p.LoadTime),
+		fmt.Sprintf("turntime %d", p.TurnTime),
+		fmt.Sprintf("rows %d", p.Rows),
+		fmt.Sprintf("cols %d", p.Cols),
+		fmt.Sprintf("turns %d", p.Turns),
+		fmt.Sprintf("viewradius2 %d", p.ViewRadius2),
+		fmt.Sprintf("attackradius2 %d", p.AttackRadius2),
+		fmt.Sprintf("spawnradius2 %d", p.SpawnRadius2),
+		fmt.Sprintf("player_seed %d", p.PlayerSeed+int64(player)*7919),
+		"ready",
+	}
+}
+
+func (g *Game) TurnLines(player int) []string {
+	res := []string{fmt.Sprintf("turn %d", g.turn+1)}
+	res = append(res, g.State(player)...)
+	return append(res, "go")
+}
+
+func (g *Game) EndLines(player int) []string {
+	res := []string{"end", fmt.Sprintf("players %d", g.players)}
+	scores := make([]string, g.players)
+	for owner, score := range g.score {
+		scores[g.rel(player, owner)] = strconv.Itoa(score)
+	}
+	res = append(res, "score "+strings.Join(scores, " "))
+	res = append(res, g.State(player)...)
+	return append(res, "go")
+}
+
+// SetOrders parses the order lines of the player.
+// Invalid orders are ignored and reported back as errors.
+func (g *Game) SetOrders(player int, lines []string) (errs []os.Error) {
+	for _, line := range lines {
+		var row, col int
+		var dir string
+		if n, _ := fmt.Sscanf(line, "o %d %d %s", &row, &col, &dir); n != 3 || len(dir) != 1 {
+			errs = append(errs, fmt.Errorf("invalid order format: %s", line))
+			continue
+		}
+		if row < 0 || row >= g.t.Rows || col < 0 || col >= g.t.Cols {
+			errs = append(errs, fmt.Errorf("out of bounds: %s", line))
+			continue
+		}
+		d := Direction(dir[0])
+		if d != North && d != East && d != South && d != West {
+			errs = append(errs, fmt.Errorf("invalid direction: %s", line))
+			continue
+		}
+		ant := g.antAt[g.t.Loc(row, col)]
+		if ant == nil || ant.Owner != player {
+			errs = append(errs, fmt.Errorf("no ant to move: %s", line))
+			continue
+		}
+		if ant.Dir != 0 {
+			errs = append(errs, fmt.Errorf("ant has been already ordered: %s", line))
+			continue
+		}
+		newLoc := g.t.NewLoc(ant.Loc, d)
+		if g.terrain[newLoc] == Water || g.hasFood(newLoc) {
+			errs = append(errs, fmt.Errorf("destination is not passable: %s", line))
+			continue
+		}
+		ant.Dir = d
+	}
+	return
+}
+
+// FinishTurn resolves the orders and advances the game by one turn.
+func (g *Game) FinishTurn() {
+	g.dead = nil
+	g.doMoves()
+	g.doAttack()
+	g.doRaze()
+	g.doSpawn()
+	g.doGather()
+	for player := 0; player < g.players; player++ {
+		if g.rnd.Intn(100) < g.opts.FoodPercent {
+			g.addRandomFood()
+		}
+	}
+	g.turn++
+	if g.GameOver() {
+		g.finish()
+	}
+}
+
+func (g *Game) removeDead() {
+	live := g.ants[:0]
+	for _, ant := range g.ants {
+		if ant.Alive {
+			live = append(live, ant)
+			continue
+		}
+		if g.antAt[ant.Loc] == ant {
+			g.antAt[ant.Loc] = nil
+		}
+		g.dead = append(g.dead, ant)
+	}
+	g.ants = live
+}
+
+// doMoves moves all ordered ants. If several ants end up in one cell, they all die.
+func (g *Game) doMoves() {
+	for _, ant := range g.ants {
+		g.antAt[ant.Loc] = nil
+		if ant.Dir != 0 {
+			ant.Loc = g.t.NewLoc(ant.Loc, ant.Dir)
+			ant.Dir = 0
+		}
+	}
+	for _, ant := range g.ants {
+		if other := g.antAt[ant.Loc]; other != nil {
+			other.Alive = false
+			ant.Alive = false
+			continue
+		}
+		g.antAt[ant.Loc] = ant
+	}
+	g.removeDead()
+}
+
+// doAttack kills ants according to the focus battle rule, see Combat.
+func (g *Game) doAttack() {
+	fighters := make([]Fighter, len(g.ants))
+	for i, ant := range g.ants {
+		fighters[i] = Fighter{Loc: ant.Loc, Owner: ant.Owner}
+	}
+	for i, dead := range g.combat.Resolve(fighters) {
+		if dead {
+			g.ants[i].Alive = false
+		}
+	}
+	g.removeDead()
+}
+
+func (g *Game) doRaze() {
+	for _, hill := range g.hills {
+		ant := g.antAt[hill.Loc]
+		if hill.Razed || ant == nil || ant.Owner == hill.Owner {
+			continue
+		}
+		hill.Razed = true
+		g.score[ant.Owner] += 2
+		g.score[hill.Owner]--
+	}
+}
+
+func (g *Game) doSpawn() {
+	for _, ind := range g.rnd.Perm(len(g.hills)) {
+		hill := g.hills[ind]
+		if hill.Razed || g.hive[hill.Owner] == 0 || g.antAt[hill.Loc] != nil {
+			continue
+		}
+		g.hive[hill.Owner]--
+		ant := &gameAnt{Loc: hill.Loc, Owner: hill.Owner, Alive: true}
+		g.ants = append(g.ants, ant)
+		g.antAt[ant.Loc] = ant
+	}
+}
+
+// doGather gives food to the only player which has ants in the spawn radius.
+// Food contested by several players is destroyed.
+func (g *Game) doGather() {
+	left := g.food[:0]
+	for _, loc := range g.food {
+		owner := -1
+		contested := false
+		for i := range g.spawnRow {
+			ant := g.antAt[g.t.ShiftLoc(loc, g.spawnRow[i], g.spawnCol[i])]
+			if ant == nil {
+				continue
+			}
+			if owner == -1 {
+				owner = ant.Owner
+			} else if owner != ant.Owner {
+				contested = true
+			}
+		}
+		if owner == -1 {
+			left = append(left, loc)
+			continue
+		}
+		if !contested {
+			g.hive[owner]++
+		}
+	}
+	g.food = left
+}
+
+// Player is a participant of a local game talking the engine text protocol.
+type Player interface {
+	// Turn sends lines to the player and returns its answer up to "go".
+	Turn(lines []string) (orders []string, err os.Error)
+	// End sends the final lines to the player. No answer is expected.
+	End(lines []string)
+}
+
+// RunGame plays the game until it's over.
+func RunGame(g *Game, players []Player) {
+	for i, pl := range players {
+		if _, err := pl.Turn(g.SetupLines(i)); err != nil {
+			g.Drop(i, err)
+		}
+	}
+	for !g.GameOver() {
+		for i, pl := range players {
+			if !g.Active(i) {
+				continue
+			}
+			orders, err := pl.Turn(g.TurnLines(i))
+			if err != nil {
+				g.Drop(i, err)
+				continue
+			}
+			for _, err := range g.SetOrders(i, orders) {
+				fmt.Fprintf(os.Stderr, "turn %d: player %d: %v\n", g.turn+1, i, err)
+			}
+		}
+		g.FinishTurn()
+	}
+	for i, pl := range players {
+		pl.End(g.EndLines(i))
+	}
+}
+
+// GenerateGameMap creates a random map with hills spread along the diagonal.
+func GenerateGameMap(t Torus, players int, waterPercent int, seed int64) *GameMap {
+	rnd := rand.New(rand.NewSource(seed))
+	gm := &GameMap{
+		T:       t,
+		Players: players,
+		Terrain: make([]Terrain, t.Size()),
+		Hills:   make([][]Location, players),
+	}
+	for player := 0; player < players; player++ {
+		row := (2*player + 1) * t.Rows / (2 * players)
+		col := (2*player + 1) * t.Cols / (2 * players)
+		gm.Hills[player] = []Location{t.Loc(row, col)}
+	}
+	for loc := range gm.Terrain {
+		gm.Terrain[loc] = Land
+		if rnd.Intn(100) >= waterPercent {
+			continue
+		}
+		nearHill := false
+		for _, hills := range gm.Hills {
+			for _, hill := range hills {
+				dr := t.Row(hill) - t.Row(Location(loc))
+				dc := t.Col(hill) - t.Col(Location(loc))
+				if dr*dr+dc*dc <= 8 {
+					nearHill = true
+				}
+			}
+		}
+		if !nearHill {
+			gm.Terrain[loc] = Water
+		}
+	}
+	return gm
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"testing"
+)
+
+type engineAnt struct {
+	Row, Col int
+	Owner    int
+	Dir      Direction
+}
+
+type engineTest struct {
+	Name  string
+	Ants  []engineAnt
+	Food  [][2]int
+	Hills []engineAnt // Dir is ignored
+	Alive []bool      // by ant index after the turn
+	Hive  []int       // by player after the turn
+	Score []int       // by player after the turn
+}
+
+var engineTests = []engineTest{
+	{
+		Name: "collision of own ants",
+		Ants: []engineAnt{
+			{5, 5, 0, East},
+			{5, 7, 0, West},
+			{0, 0, 1, 0},
+		},
+		Alive: []bool{false, false, true},
+	},
+	{
+		Name: "one on one",
+		Ants: []engineAnt{
+			{5, 5, 0, 0},
+			{5, 7, 1, 0},
+		},
+		Alive: []bool{false, false},
+	},
+	{
+		Name: "two on one",
+		Ants: []engineAnt{
+			{5, 5, 0, 0},
+			{6, 5, 0, 0},
+			{5, 7, 1, 0},
+		},
+		Alive: []bool{true, true, false},
+	},
+	{
+		Name: "gathering",
+		Ants: []engineAnt{
+			{5, 5, 0, 0},
+			{0, 0, 1, 0},
+		},
+		Food:  [][2]int{{5, 6}},
+		Alive: []bool{true, true},
+		Hive:  []int{1, 0},
+	},
+	{
+		Name: "razing",
+		Ants: []engineAnt{
+			{5, 5, 0, East},
+		},
+		Hills: []engineAnt{
+			{5, 6, 1, 0},
+			{0, 0, 0, 0},
+		},
+		Alive: []bool{true},
+		Score: []int{3, 0},
+	},
+}
+
+func newEngineTestGame(test engineTest) *Game {
+	t := Torus{Rows: 16, Cols: 16}
+	gm := &GameMap{
+		T:       t,
+		Players: 2,
+		Terrain: make([]Terrain, t.Size()),
+		Hills:   make([][]Location, 2),
+	}
+	for loc := range gm.Terrain {
+		gm.Terrain[loc] = Land
+	}
+	for _, hill := range test.Hills {
+		gm.Hills[hill.Owner] = append(gm.Hills[hill.Owner], t.Loc(hill.Row, hill.Col))
+	}
+	g := NewGame(gm, GameOptions{
+		Params: Params{Turns: 10, ViewRadius2: 77, AttackRadius2: 5, SpawnRadius2: 1},
+	})
+	// Forget ants spawned on hills
+	for _, ant := range g.ants {
+		g.antAt[ant.Loc] = nil
+	}
+	g.ants = nil
+	g.food = nil
+	for _, food := range test.Food {
+		g.food = append(g.food, t.Loc(food[0], food[1]))
+	}
+	return g
+}
+
+func TestEngine(t *testing.T) {
+	for _, test := range engineTests {
+		g := newEngineTestGame(test)
+		var ants []*gameAnt
+		for _, a := range test.Ants {
+			ant := &gameAnt{Loc: g.t.Loc(a.Row, a.Col), Owner: a.Owner, Dir: a.Dir, Alive: true}
+			g.ants = append(g.ants, ant)
+			g.antAt[ant.Loc] = ant
+			ants = append(ants, ant)
+		}
+		g.FinishTurn()
+		for i, ant := range ants {
+			if ant.Alive != test.Alive[i] {
+				t.Errorf("%s: ant #%d alive: %v, want: %v", test.Name, i, ant.Alive, test.Alive[i])
+			}
+		}
+		for player, hive := range test.Hive {
+			if g.hive[player] != hive {
+				t.Errorf("%s: player %d hive: %d, want: %d", test.Name, player, g.hive[player], hive)
+			}
+		}
+		for player, score := range test.Score {
+			if g.score[player] != score {
+				t.Errorf("%s: player %d score: %d, want: %d", test.Name, player, g.score[player], score)
+			}
+		}
+	}
+}
+
+func TestRunGame(t *testing.T) {
+	gm := GenerateGameMap(Torus{Rows: 30, Cols: 30}, 2, 10, 1)
+	g := NewGame(gm, GameOptions{
+		Params: Params{
+			Turns:         50,
+			ViewRadius2:   77,
+			AttackRadius2: 5,
+			SpawnRadius2:  1,
+			PlayerSeed:    1,
+		},
+		FoodPercent: DefaultFoodPercent,
+		FoodStart:   DefaultFoodStart,
+	})
+	RunGame(g, []Player{NewBotPlayer(new(RandomBot)), NewBotPlayer(new(RandomBot))})
+	if g.Turn() == 0 {
+		t.Errorf("No turns were played")
+	}
+	for player := 0; player < 2; player++ {
+		if g.out[player] {
+			t.Errorf("player %d is out", player)
+		}
+	}
+}
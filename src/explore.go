@@ -0,0 +1,92 @@
+package main
+
+import (
+	"sort"
+)
+
+// Explorer chooses squares worth visiting: the frontier of the known map
+// and the squares which have not been seen for a long time.
+// The map is split into blocks of GridSize, and only the best square
+// of every block is a target, so the planner is not flooded.
+type Explorer struct {
+	m *Map
+	k int
+	// The number of targets is limited by MaxTargets, 0 means no limit
+	MaxTargets int
+}
+
+func NewExplorer(m *Map, k int) *Explorer {
+	return &Explorer{m: m, k: k, MaxTargets: MaxExploreTargets}
+}
+
+// Frontier reports whether the land square has an unknown neighbour.
+func (e *Explorer) Frontier(loc Location) bool {
+	if e.m.Terrain[loc] != Land {
+		return false
+	}
+	for _, dir := range Dirs {
+		if e.m.Terrain[e.m.T.NewLoc(loc, dir)] == Unknown {
+			return true
+		}
+	}
+	return false
+}
+
+// Score returns how much it's worth to visit the square, 0 if it's not needed.
+func (e *Explorer) Score(loc Location) int {
+	if e.Frontier(loc) {
+		return NeverVisitedScore
+	}
+	if e.m.Terrain[loc] != Land || e.m.Visible(loc) {
+		return 0
+	}
+	if e.m.LastVisited[loc] == 0 {
+		return NeverVisitedScore2
+	}
+	score := VisitScore * (e.m.Turn() - e.m.LastSeen(loc))
+	if score > NeverVisitedScore2 {
+		score = NeverVisitedScore2
+	}
+	return score
+}
+
+// Stale reports whether the land square is on the frontier
+// or has not been seen for ExploreStaleTurns.
+func (e *Explorer) Stale(loc Location) bool {
+	return e.Frontier(loc) ||
+		e.m.Terrain[loc] == Land && e.m.Turn()-e.m.LastSeen(loc) >= ExploreStaleTurns
+}
+
+// Targets returns the best stale square of every block with its score.
+// Only MaxTargets squares with the best scores are returned.
+func (e *Explorer) Targets() (targets []Location, scores []int) {
+	t := e.m.T
+	for row := 0; row < t.Rows; row += e.k {
+		for col := 0; col < t.Cols; col += e.k {
+			best := 0
+			var bestLoc Location
+			for i := row; i < row+e.k && i < t.Rows; i++ {
+				for j := col; j < col+e.k && j < t.Cols; j++ {
+					loc := t.Loc(i, j)
+					if !e.Stale(loc) {
+						continue
+					}
+					if score := e.Score(loc); score > best {
+						best = score
+						bestLoc = loc
+					}
+				}
+			}
+			if best > 0 {
+				targets = append(targets, bestLoc)
+				scores = append(scores, best)
+			}
+		}
+	}
+	if e.MaxTargets > 0 && len(targets) > e.MaxTargets {
+		sort.Sort(&sorter{targets, scores})
+		targets = targets[:e.MaxTargets]
+		scores = scores[:e.MaxTargets]
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestExplorer(t *testing.T) {
+	tt := Torus{16, 16}
+	m := NewMap(tt, 4)
+	e := NewExplorer(m, 8)
+	m.Update([]Input{
+		{What: Hill, Row: 8, Col: 8, Owner: Me},
+		{What: Ant, Row: 8, Col: 8, Owner: Me},
+	})
+	m.Update([]Input{
+		{What: Hill, Row: 8, Col: 8, Owner: Me},
+		{What: Ant, Row: 8, Col: 9, Owner: Me},
+	})
+	m.Update([]Input{
+		{What: Ant, Row: 8, Col: 13, Owner: Me},
+	})
+	tests := []struct {
+		Row, Col int
+		Score    int
+	}{
+		// Unknown
+		{0, 0, 0},
+		// Next to unknown
+		{8, 15, NeverVisitedScore},
+		// Visible
+		{8, 13, 0},
+		// Has been seen, but never visited
+		{8, 7, NeverVisitedScore2},
+		// Has been visited on the turn 1 and seen on the turn 2
+		{8, 8, VisitScore},
+	}
+	for i, test := range tests {
+		if got := e.Score(tt.Loc(test.Row, test.Col)); got != test.Score {
+			t.Errorf("test #%d: Score(%d, %d) = %d, want: %d", i, test.Row, test.Col, got, test.Score)
+		}
+	}
+	targets, scores := e.Targets()
+	if len(targets) != 4 || len(scores) != 4 {
+		t.Fatalf("Targets: %v, scores: %v", targets, scores)
+	}
+	for i, loc := range targets {
+		if scores[i] != e.Score(loc) || scores[i] != NeverVisitedScore {
+			t.Errorf("Target #%d at %d: score %d, want: %d", i, loc, scores[i], NeverVisitedScore)
+		}
+	}
+}
+
+func TestExplorerStale(t *testing.T) {
+	tt := Torus{8, 16}
+	m := NewMap(tt, 32)
+	e := NewExplorer(m, 8)
+	// The whole map is seen on the first turn and never again
+	m.Update([]Input{
+		{What: Ant, Row: 4, Col: 4, Owner: Me},
+		{What: Ant, Row: 4, Col: 12, Owner: Me},
+	})
+	for turn := 0; turn < ExploreStaleTurns; turn++ {
+		if targets, scores := e.Targets(); len(targets) != 0 {
+			t.Fatalf("turn %d: Targets: %v, scores: %v, want none", m.Turn(), targets, scores)
+		}
+		m.Update(nil)
+	}
+	if targets, scores := e.Targets(); len(targets) != 2 {
+		t.Errorf("Targets: %v, scores: %v, want one in each block", targets, scores)
+	}
+	e.MaxTargets = 1
+	if targets, scores := e.Targets(); len(targets) != 1 {
+		t.Errorf("MaxTargets = 1: Targets: %v, scores: %v", targets, scores)
+	}
+}
@@ -1,30 +1,80 @@
 package main
 
-const bigN = 20000
+import (
+	"fmt"
+	"os"
+)
 
 const NoPath = (1 << 31) - 1
 
+// Default memory ceiling of FairLocator distances
+const MaxFairLocatorBytes = 400 * 1000 * 1000
+
+// The scan of a pair is split between workers only if each of them gets this many cells
+const MinCellsPerWorker = 512
+
+// Update asks whether to go on once per this many pairs
+const UpdateCheckPairs = 16
+
 type locPair struct {
 	a Location
 	b Location
 }
 
+// rangeJob asks a worker to update distances for loc looking at from
+// to the cells with indices in [begin, end).
+type rangeJob struct {
+	loc, from  Location
+	begin, end int
+}
+
+// FairLocator keeps distances between all pairs of discovered cells.
+// The storage grows as cells are added, but not beyond MaxBytes,
+// including the old storage while it's copied.
+// Cells added after that are not indexed, and the distances to them are NoPath.
 type FairLocator struct {
-	conn    Connector
-	big     []int16
-	loc2ind []int
-	ind2loc []Location
+	conn     Connector
+	big      []int16 // distances of pairs of indexed cells, see bigIndex
+	loc2ind  []int
+	ind2loc  []Location
+	MaxBytes int
+	full     bool
+
+	// Workers is the number of goroutines updating a pair, 1 if not set.
+	// Each of them scans its own range of cells, so they write different distances.
+	// The goroutines are started on the first parallel update and live as long as the locator.
+	Workers        int
+	cellsPerWorker int
+	started        int
+	jobs           chan rangeJob
+	results        chan bool
+
+	// cells and edges removed by Remove and RemoveEdge, nil if none
+	blocked      []bool
+	blockedEdges map[locPair]bool
+	// edges of the cells ever removed, they may be gone from the connector
+	removedConn map[Location][]Location
+	everRemoved []bool
+
+	// parts under repair, the rows of big before repairRow are checked against them,
+	// and parts removed since the repair has started
+	parts     []removedPart
+	repairRow int
+	reset     []bool
+	queued    []removedPart
 
 	// indices to update
 	toUpdate []locPair
 	buf      []locPair
 }
 
-func NewFairLocator(conn Connector, big []int16) *FairLocator {
+// NewFairLocator returns a locator for size locations, like Torus.Size().
+func NewFairLocator(conn Connector, size int) *FairLocator {
 	return &FairLocator{
-		conn:    conn,
-		big:     big,
-		loc2ind: make([]int, 40000),
+		conn:           conn,
+		loc2ind:        make([]int, size),
+		MaxBytes:       MaxFairLocatorBytes,
+		cellsPerWorker: MinCellsPerWorker,
 	}
 }
 
@@ -44,8 +94,36 @@ func (l *FairLocator) bigIndex(from, to Location) int {
 	if fromInd > toInd {
 		fromInd, toInd = toInd, fromInd
 	}
-	s := bigN*fromInd - (fromInd*(fromInd+1))/2
-	return s + (toInd - fromInd - 1)
+	// The pairs with the new cell go after all the previous ones,
+	// so the storage grows at the end
+	return toInd*(toInd-1)/2 + fromInd
+}
+
+// grow makes room for the distances of a new cell.
+// It returns false if the memory ceiling is reached.
+func (l *FairLocator) grow() bool {
+	n := len(l.ind2loc) + 1
+	need := n * (n - 1) / 2
+	if need*2 > l.MaxBytes {
+		return false
+	}
+	if need > cap(l.big) {
+		newCap := 2 * cap(l.big)
+		if newCap < need {
+			newCap = need
+		}
+		if (cap(l.big)+newCap)*2 > l.MaxBytes {
+			newCap = l.MaxBytes/2 - cap(l.big)
+		}
+		if newCap < need {
+			return false
+		}
+		big := make([]int16, len(l.big), newCap)
+		copy(big, l.big)
+		l.big = big
+	}
+	l.big = l.big[:need]
+	return true
 }
 
 func (l *FairLocator) set(from, to Location, dist int) {
@@ -57,66 +135,381 @@ func (l *FairLocator) set(from, to Location, dist int) {
 
 // Update distances for loc looking at from
 func (l *FairLocator) updatePair(loc, from Location) {
-	was := false
-	if loc == from {
+	if loc == from || !l.linked(loc, from) {
 		return
 	}
-	for i := 0; i < len(l.ind2loc); i++ {
-		to := l.ind2loc[i]
-		if to == loc || to == from || !l.hasLoc(to) {
-			continue
+	n := len(l.ind2loc)
+	workers := l.Workers
+	if workers > n/l.cellsPerWorker {
+		workers = n / l.cellsPerWorker
+	}
+	was := false
+	if workers <= 1 {
+		was = l.updateRange(loc, from, 0, n)
+	} else {
+		// The distances from loc to the cells of different ranges are different,
+		// and none of them is read by the other workers.
+		// The first range is scanned by this goroutine.
+		l.startWorkers(workers - 1)
+		for w := 1; w < workers; w++ {
+			l.jobs <- rangeJob{loc, from, w * n / workers, (w + 1) * n / workers}
 		}
-		curDist := l.Dist(loc, to)
-		newDist := l.Dist(from, to)
-		if newDist != NoPath && newDist+1 < curDist {
-			l.set(loc, to, newDist+1)
-			was = true
+		was = l.updateRange(loc, from, 0, n/workers)
+		for w := 1; w < workers; w++ {
+			if <-l.results {
+				was = true
+			}
 		}
 	}
 	if was {
 		for _, conn := range l.conn.Conn(loc) {
-			if conn != from && l.hasLoc(conn) {
+			if conn != from && l.hasLoc(conn) && l.linked(conn, loc) {
 				l.toUpdate = append(l.toUpdate, locPair{conn, loc})
 			}
 		}
 	}
 }
 
+// startWorkers makes sure n goroutines wait for range jobs.
+func (l *FairLocator) startWorkers(n int) {
+	if l.jobs == nil {
+		l.jobs = make(chan rangeJob)
+		l.results = make(chan bool)
+	}
+	for ; l.started < n; l.started++ {
+		go func() {
+			for job := range l.jobs {
+				l.results <- l.updateRange(job.loc, job.from, job.begin, job.end)
+			}
+		}()
+	}
+}
+
+// stopWorkers stops the goroutines, they are started again if needed.
+func (l *FairLocator) stopWorkers() {
+	if l.jobs != nil {
+		close(l.jobs)
+		l.jobs = nil
+		l.started = 0
+	}
+}
+
+// updateRange updates distances for loc looking at from to the cells with indices in [begin, end).
+// It returns true if some of them is shortened.
+func (l *FairLocator) updateRange(loc, from Location, begin, end int) (was bool) {
+	for i := begin; i < end; i++ {
+		to := l.ind2loc[i]
+		if to == loc || to == from || !l.hasLoc(to) {
+			continue
+		}
+		if l.blocked != nil && l.blocked[to] {
+			// The distances to removed cells are found by endDist
+			continue
+		}
+		curDist := l.stored(loc, to)
+		newDist := l.stored(from, to)
+		if newDist != NoPath && newDist+1 < curDist {
+			l.set(loc, to, newDist+1)
+			was = true
+		}
+	}
+	return
+}
+
 func (l *FairLocator) NeedUpdate() bool {
-	return len(l.toUpdate) > 0
+	return len(l.toUpdate) > 0 || len(l.parts) > 0 || len(l.queued) > 0
 }
 
-func (l *FairLocator) Add(locs ...Location) {
+// Add indexes new cells. It returns an error the first time the memory ceiling
+// doesn't allow to index all of them, the cells added after that are just not indexed.
+func (l *FairLocator) Add(locs ...Location) os.Error {
 	//	fmt.Printf("Add(%d)\n", loc)
-	for _, loc := range locs {
+	for i, loc := range locs {
 		if l.hasLoc(loc) {
 			continue
 		}
+		if l.full {
+			return nil
+		}
+		if !l.grow() {
+			l.full = true
+			return fmt.Errorf("FairLocator: the memory ceiling of %d bytes is reached with %d cells, %d cells are not indexed",
+				l.MaxBytes, len(l.ind2loc), len(locs)-i)
+		}
 		ind := len(l.ind2loc)
 		l.ind2loc = append(l.ind2loc, loc)
 		l.loc2ind[int(loc)] = ind + 1
+		l.connect(loc)
+	}
+	return nil
+}
 
+// connect links the indexed cell with its neighbours.
+func (l *FairLocator) connect(loc Location) {
+	for _, conn := range l.conn.Conn(loc) {
+		if !l.hasLoc(conn) || !l.linked(loc, conn) {
+			continue
+		}
+		l.set(loc, conn, 1)
+		l.toUpdate = append(l.toUpdate, locPair{loc, conn})
+		l.toUpdate = append(l.toUpdate, locPair{conn, loc})
+	}
+}
+
+func edgeKey(a, b Location) locPair {
+	if a > b {
+		a, b = b, a
+	}
+	return locPair{a, b}
+}
+
+// linked reports whether neither the neighbour cells nor the edge between them are removed.
+func (l *FairLocator) linked(a, b Location) bool {
+	if l.blocked == nil {
+		return true
+	}
+	return !l.blocked[a] && !l.blocked[b] && !l.blockedEdges[edgeKey(a, b)]
+}
+
+func (l *FairLocator) initBlocked() {
+	if l.blocked == nil {
+		l.blocked = make([]bool, len(l.loc2ind))
+		l.blockedEdges = make(map[locPair]bool)
+		l.removedConn = make(map[Location][]Location)
+		l.everRemoved = make([]bool, len(l.loc2ind))
+	}
+}
+
+// Remove blocks the cells, like squares taken by my idle ants or water
+// first taken for land. The distances through them are reset by the following updates,
+// which find them again. Paths may still start or end at a removed cell.
+func (l *FairLocator) Remove(locs ...Location) os.Error {
+	l.initBlocked()
+	var removed []Location
+	for _, loc := range locs {
+		if l.blocked[loc] || !l.hasLoc(loc) {
+			l.blocked[loc] = true
+			continue
+		}
+		removed = append(removed, loc)
+		if l.everRemoved[loc] {
+			continue
+		}
+		// The cells may be gone from the connector already,
+		// so their edges are taken from their own side
+		l.everRemoved[loc] = true
 		for _, conn := range l.conn.Conn(loc) {
-			if !l.hasLoc(conn) {
+			l.removedConn[conn] = append(l.removedConn[conn], loc)
+			l.removedConn[loc] = append(l.removedConn[loc], conn)
+		}
+	}
+	var parts []removedPart
+	for _, loc := range removed {
+		lo := l.bfs(loc)
+		parts = append(parts, removedPart{lo, lo, 0})
+	}
+	for _, loc := range removed {
+		l.blocked[loc] = true
+	}
+	l.addParts(parts...)
+	return nil
+}
+
+// RemoveEdge blocks the edge between the neighbour cells.
+func (l *FairLocator) RemoveEdge(a, b Location) {
+	l.initBlocked()
+	if l.blockedEdges[edgeKey(a, b)] {
+		return
+	}
+	if !l.hasLoc(a) || !l.hasLoc(b) || l.blocked[a] || l.blocked[b] {
+		// No distance goes through the edge, or it's reset with the removed cell
+		l.blockedEdges[edgeKey(a, b)] = true
+		return
+	}
+	part := removedPart{l.bfs(a), l.bfs(b), 1}
+	l.blockedEdges[edgeKey(a, b)] = true
+	l.addParts(part)
+}
+
+// Restore unblocks the cells removed by Remove, which are free again.
+func (l *FairLocator) Restore(locs ...Location) {
+	for _, loc := range locs {
+		if l.blocked == nil || !l.blocked[loc] {
+			continue
+		}
+		l.blocked[loc] = false
+		if l.hasLoc(loc) {
+			l.connect(loc)
+		}
+	}
+}
+
+// bfs returns the distances from the cell by indices, len(ind2loc) if unreachable.
+// The removed cells and edges are passed, and the edges of the cells ever removed
+// are added to the ones of the connector: the stored distances may still go
+// through them, and the distances found are not longer than those.
+func (l *FairLocator) bfs(from Location) []int {
+	n := len(l.ind2loc)
+	dist := make([]int, n)
+	for i := range dist {
+		dist[i] = n
+	}
+	dist[l.loc2ind[from]-1] = 0
+	q := []Location{from}
+	for len(q) > 0 {
+		loc := q[0]
+		q = q[1:]
+		d := dist[l.loc2ind[loc]-1]
+		for _, conns := range [][]Location{l.conn.Conn(loc), l.removedConn[loc]} {
+			for _, conn := range conns {
+				if !l.hasLoc(conn) {
+					continue
+				}
+				if ind := l.loc2ind[conn] - 1; dist[ind] == n {
+					dist[ind] = d + 1
+					q = append(q, conn)
+				}
+			}
+		}
+	}
+	return dist
+}
+
+// removedPart describes a removed cell or edge by the distances from its ends
+// before the removal, and the length between the ends.
+// The cells indexed after the removal are not in loA and loB.
+type removedPart struct {
+	loA, loB []int
+	extra    int
+}
+
+// lower returns the lower bound of the length of a path between the cells
+// through the part.
+func (p *removedPart) lower(i, j int) int {
+	ab := loAt(p.loA, i) + loAt(p.loB, j)
+	if ba := loAt(p.loB, i) + loAt(p.loA, j); ba < ab {
+		ab = ba
+	}
+	return ab + p.extra
+}
+
+// loAt returns the distance from an end of a removed part,
+// 0 for the cells indexed after the removal.
+func loAt(lo []int, ind int) int {
+	if ind < len(lo) {
+		return lo[ind]
+	}
+	return 0
+}
+
+// addParts queues the removed parts for the next repair,
+// the repair in progress is finished first.
+func (l *FairLocator) addParts(parts ...removedPart) {
+	l.queued = append(l.queued, parts...)
+}
+
+// removedBetween reports whether the stored distance between the cells
+// might go through a part under repair or queued for it.
+func (l *FairLocator) removedBetween(i, j, d int) bool {
+	for _, parts := range [][]removedPart{l.parts, l.queued} {
+		for k := range parts {
+			if d >= parts[k].lower(i, j) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// repairStep resets the distances which might go through the removed parts,
+// one row of big per call of ok, and returns false if ok has stopped it.
+// Every stored distance is the length of some path, and a path through a removed part
+// is not shorter than the one via the closest ends of it.
+// When all rows are checked, the cells with reset distances are updated again.
+// The pairs are not updated before that, so the distances through the parts
+// do not spread to the rows checked already. Dist does not answer with the distances
+// which are to be reset, so the rows not checked yet are never seen.
+func (l *FairLocator) repairStep(ok func() bool) bool {
+	if len(l.parts) == 0 {
+		return true
+	}
+	n := len(l.ind2loc)
+	if len(l.reset) < n {
+		l.reset = append(l.reset, make([]bool, n-len(l.reset))...)
+	}
+	for ; l.repairRow < n; l.repairRow++ {
+		if ok != nil && !ok() {
+			return false
+		}
+		j := l.repairRow
+		row := l.big[j*(j-1)/2:]
+		for i := 0; i < j; i++ {
+			d := int(row[i])
+			if d == 0 {
 				continue
 			}
-			l.set(loc, conn, 1)
-			l.toUpdate = append(l.toUpdate, locPair{loc, conn})
-			l.toUpdate = append(l.toUpdate, locPair{conn, loc})
+			for k := range l.parts {
+				if d >= l.parts[k].lower(i, j) {
+					row[i] = 0
+					l.reset[i] = true
+					l.reset[j] = true
+					break
+				}
+			}
+		}
+	}
+	for i, r := range l.reset {
+		if r {
+			// The edges of the cell might be reset, if it has been restored meanwhile
+			l.connect(l.ind2loc[i])
 		}
 	}
+	l.parts = nil
+	l.reset = nil
+	return true
 }
 
 func (l *FairLocator) UpdateStep() {
+	l.updateStep(nil)
+}
+
+// updateStep continues the repair in progress, or updates the queued pairs once
+// and starts the repair of the queued parts, so both go on when cells are
+// removed on every turn. It returns false if ok has stopped it.
+func (l *FairLocator) updateStep(ok func() bool) bool {
+	if len(l.parts) > 0 {
+		return l.repairStep(ok)
+	}
+	if !l.step(ok) {
+		return false
+	}
+	if len(l.queued) > 0 {
+		l.parts, l.queued = l.queued, nil
+		l.repairRow = 1
+	}
+	return true
+}
+
+// step updates the queued pairs while ok returns true, the rest are queued again.
+// ok is called once per UpdateCheckPairs pairs. It returns false if ok has stopped it.
+func (l *FairLocator) step(ok func() bool) bool {
 	l.toUpdate, l.buf = l.buf[:0], l.toUpdate
-	for _, pair := range l.buf {
+	for i, pair := range l.buf {
+		if ok != nil && i > 0 && i%UpdateCheckPairs == 0 && !ok() {
+			l.toUpdate = append(l.toUpdate, l.buf[i:]...)
+			return false
+		}
 		l.updatePair(pair.a, pair.b)
 	}
+	return true
 }
 
+// Update works until everything is updated or ok returns false once.
 func (l *FairLocator) Update(ok func() bool) {
 	for l.NeedUpdate() && ok() {
-		l.UpdateStep()
+		if !l.updateStep(ok) {
+			return
+		}
 	}
 }
 
@@ -124,6 +517,19 @@ func (l *FairLocator) Dist(from, to Location) int {
 	if from == to {
 		return 0
 	}
+	if l.blocked != nil && (l.blocked[from] || l.blocked[to]) {
+		return l.endDist(from, to)
+	}
+	d := l.stored(from, to)
+	if d != NoPath && (len(l.parts) > 0 || len(l.queued) > 0) &&
+		l.removedBetween(l.loc2ind[from]-1, l.loc2ind[to]-1, d) {
+		return NoPath
+	}
+	return d
+}
+
+// stored returns the stored distance between the different cells.
+func (l *FairLocator) stored(from, to Location) int {
 	ind := l.bigIndex(from, to)
 	if ind == -1 {
 		return NoPath
@@ -134,3 +540,46 @@ func (l *FairLocator) Dist(from, to Location) int {
 	}
 	return val
 }
+
+// endDist returns the distance between the cells, at least one of which is removed.
+// The path starts or ends there, but does not pass through the other removed cells.
+func (l *FairLocator) endDist(from, to Location) int {
+	if !l.blocked[from] {
+		from, to = to, from
+	}
+	if !l.hasLoc(from) {
+		return NoPath
+	}
+	best := NoPath
+	for _, conn := range l.conn.Conn(from) {
+		if !l.hasLoc(conn) || l.blockedEdges[edgeKey(from, conn)] || !l.hasConn(conn, from) {
+			continue
+		}
+		if conn == to {
+			return 1
+		}
+		if l.blocked[conn] {
+			continue
+		}
+		if d := l.Dist(conn, to); d != NoPath && d+1 < best {
+			best = d + 1
+		}
+	}
+	return best
+}
+
+// hasConn reports whether the connector links a to b,
+// it does not for a removed cell which has turned out to be water.
+func (l *FairLocator) hasConn(a, b Location) bool {
+	for _, conn := range l.conn.Conn(a) {
+		if conn == b {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryBytes returns the memory allocated for distances.
+func (l *FairLocator) MemoryBytes() int {
+	return cap(l.big)*2 + len(l.loc2ind)*4
+}
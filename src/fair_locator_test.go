@@ -3,6 +3,7 @@ package main
 import (
 	//	"fmt"
 	"rand"
+	"runtime"
 	"testing"
 )
 
@@ -133,17 +134,20 @@ var fairLocatorTests = []fairLocatorTest{
 	pseudoRandomTest(200, 0, 10),
 }
 
-func cleanBig() {
-	for i := range big {
-		big[i] = 0
-	}
+func TestFairLocator(t *testing.T) {
+	testFairLocator(t, 1)
 }
 
-func TestFairLocator(t *testing.T) {
+func TestFairLocatorWorkers(t *testing.T) {
+	testFairLocator(t, 3)
+}
+
+func testFairLocator(t *testing.T, workers int) {
 	for testInd, test := range fairLocatorTests {
 		for runInd, run := range test.run {
-			cleanBig()
-			l := NewFairLocator(&test, big)
+			l := NewFairLocator(&test, test.n)
+			l.Workers = workers
+			l.cellsPerWorker = 1
 			for _, loc := range run {
 				l.Add(loc)
 				for l.NeedUpdate() {
@@ -176,3 +180,266 @@ func TestFairLocator(t *testing.T) {
 		}
 	}
 }
+
+func TestFairLocatorMemoryCeiling(t *testing.T) {
+	test := pseudoRandomTest(10, 0, 50)
+	l := NewFairLocator(&test, test.n)
+	// Distances of 5 cells take 10 pairs, and the storage of 6 pairs
+	// is copied to them
+	l.MaxBytes = 32
+	if err := l.Add(0, 1, 2, 3, 4); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := l.Add(5, 6); err == nil {
+		t.Fatalf("Add beyond the ceiling has not failed")
+	}
+	if err := l.Add(7); err != nil {
+		t.Errorf("Add after the ceiling is reported: %v", err)
+	}
+	if l.MemoryBytes()-len(l.loc2ind)*4 > l.MaxBytes {
+		t.Errorf("%d bytes of distances, the ceiling is %d", l.MemoryBytes()-len(l.loc2ind)*4, l.MaxBytes)
+	}
+	for l.NeedUpdate() {
+		l.UpdateStep()
+	}
+	if got := l.Dist(0, 6); got != NoPath {
+		t.Errorf("Dist to a cell which is not indexed: %d, want: NoPath", got)
+	}
+	if len(l.big) != 10 {
+		t.Errorf("len(big) = %d, want: 10", len(l.big))
+	}
+}
+
+// TestFairLocatorParallel checks that the parallel update makes the same steps as the sequential one.
+func TestFairLocatorParallel(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for testInd := 0; testInd < 30; testInd++ {
+		n := 2 + rnd.Intn(150)
+		test := pseudoRandomTest(n, int64(testInd), 1+rnd.Intn(10))
+		order := rnd.Perm(n)
+		seq := NewFairLocator(&test, n)
+		par := NewFairLocator(&test, n)
+		par.Workers = 1 + rnd.Intn(8)
+		par.cellsPerWorker = 1 + rnd.Intn(10)
+		for len(order) > 0 {
+			k := 1 + rnd.Intn(len(order))
+			var locs []Location
+			for _, ind := range order[:k] {
+				locs = append(locs, Location(ind))
+			}
+			order = order[k:]
+			seq.Add(locs...)
+			par.Add(locs...)
+			for step := 0; seq.NeedUpdate() || par.NeedUpdate(); step++ {
+				seq.UpdateStep()
+				par.UpdateStep()
+				if len(seq.toUpdate) != len(par.toUpdate) {
+					t.Fatalf("test #%d, step %d: %d pairs to update, want: %d", testInd, step, len(par.toUpdate), len(seq.toUpdate))
+				}
+				for i := range seq.big {
+					if seq.big[i] != par.big[i] {
+						t.Fatalf("test #%d, step %d: big[%d] = %d, want: %d", testInd, step, i, par.big[i], seq.big[i])
+					}
+				}
+			}
+		}
+		par.stopWorkers()
+	}
+}
+
+// edgeBlocker hides some edges of the connector, like RemoveEdge does.
+type edgeBlocker struct {
+	conn    Connector
+	blocked map[locPair]bool
+}
+
+func (b *edgeBlocker) Conn(loc Location) (res []Location) {
+	for _, conn := range b.conn.Conn(loc) {
+		if !b.blocked[edgeKey(loc, conn)] {
+			res = append(res, conn)
+		}
+	}
+	return
+}
+
+// endBfsDist returns the distances from the cell through the known cells.
+// Unknown land cells may be the ends of paths, like the cells removed by Remove.
+func endBfsDist(conn Connector, m *Map, known []bool, from Location) []int {
+	dist := make([]int, len(known))
+	for i := range dist {
+		dist[i] = NoPath
+	}
+	dist[from] = 0
+	if m.Terrain[from] != Land {
+		return dist
+	}
+	q := []Location{from}
+	for len(q) > 0 {
+		loc := q[0]
+		q = q[1:]
+		for _, next := range conn.Conn(loc) {
+			if dist[next] == NoPath {
+				dist[next] = dist[loc] + 1
+				if known[next] {
+					q = append(q, next)
+				}
+			}
+		}
+	}
+	return dist
+}
+
+// TestFairLocatorRemove removes cells and edges and adds the last cells
+// in the middle of updates and repairs, and compares the distances
+// with the ones found by BFS.
+func TestFairLocatorRemove(t *testing.T) {
+	for testInd := 0; testInd < 10; testInd++ {
+		rnd := rand.New(rand.NewSource(int64(testInd)))
+		tt := Torus{10 + rnd.Intn(10), 10 + rnd.Intn(10)}
+		m := landmarkTestMap(tt, 10+rnd.Intn(30), int64(testInd))
+		ref := &edgeBlocker{m, make(map[locPair]bool)}
+		cells := landmarkTestCells(m)
+		known := make([]bool, tt.Size())
+		added := make([]bool, tt.Size())
+		l := NewFairLocator(m, tt.Size())
+		// The pairs are split between the workers in some tests
+		l.Workers = 1 + testInd%3
+		l.cellsPerWorker = 16
+		late := cells[len(cells)*3/4:]
+		l.Add(cells[:len(cells)*3/4]...)
+		for _, loc := range cells[:len(cells)*3/4] {
+			known[loc] = true
+			added[loc] = true
+		}
+		var idle []Location
+		for round := 0; round < 6; round++ {
+			calls := rnd.Intn(50)
+			l.Update(func() bool {
+				calls--
+				return calls >= 0
+			})
+			if round == 4 {
+				l.Add(late...)
+				for _, loc := range late {
+					added[loc] = true
+					known[loc] = m.Terrain[loc] == Land
+				}
+			}
+			switch round % 3 {
+			case 0:
+				// Water first taken for land
+				loc := cells[rnd.Intn(len(cells))]
+				m.Terrain[loc] = Water
+				known[loc] = false
+				l.Remove(loc)
+			case 1:
+				// Squares taken by idle ants, they are left later
+				for k := 0; k < 3; k++ {
+					loc := cells[rnd.Intn(len(cells))]
+					known[loc] = false
+					idle = append(idle, loc)
+				}
+				l.Remove(idle...)
+			case 2:
+				a := cells[rnd.Intn(len(cells))]
+				conns := m.Conn(a)
+				if len(conns) > 0 {
+					b := conns[rnd.Intn(len(conns))]
+					ref.blocked[edgeKey(a, b)] = true
+					l.RemoveEdge(a, b)
+				}
+				for _, loc := range idle {
+					if m.Terrain[loc] == Land {
+						known[loc] = true
+						l.Restore(loc)
+					}
+				}
+				idle = nil
+			}
+			for l.NeedUpdate() {
+				l.UpdateStep()
+			}
+			for _, from := range cells {
+				want := endBfsDist(ref, m, known, from)
+				for _, to := range cells {
+					if (!added[from] || !added[to]) && from != to {
+						want[to] = NoPath
+					}
+					if got := l.Dist(from, to); got != want[to] {
+						t.Fatalf("test #%d, round %d: Dist(%d, %d) = %d, want: %d", testInd, round, from, to, got, want[to])
+					}
+				}
+			}
+		}
+		l.stopWorkers()
+	}
+}
+
+// TestFairLocatorInterruptedRepair asks for paths across the removed cells
+// while their repair is stopped halfway.
+func TestFairLocatorInterruptedRepair(t *testing.T) {
+	tt := Torus{20, 20}
+	m := landmarkTestMap(tt, 20, 1)
+	cells := landmarkTestCells(m)
+	rnd := rand.New(rand.NewSource(1))
+	for testInd := 0; testInd < 20; testInd++ {
+		l := NewFairLocator(m, tt.Size())
+		l.Add(cells...)
+		l.Update(func() bool { return true })
+		pf := NewPathFinder(tt, m, l)
+		for k := 0; k < 2; k++ {
+			var removed []Location
+			for i := 0; i < 10; i++ {
+				removed = append(removed, cells[rnd.Intn(len(cells))])
+			}
+			l.Remove(removed...)
+			calls := 2 + rnd.Intn(len(cells))
+			l.Update(func() bool {
+				calls--
+				return calls >= 0
+			})
+			for q := 0; q < 200; q++ {
+				from := cells[rnd.Intn(len(cells))]
+				to := cells[rnd.Intn(len(cells))]
+				// The path is not longer than the distance, it may be shorter
+				// while the distances are updated
+				if p := pf.Path(from, to); p != nil && p.Len() > l.Dist(from, to) {
+					t.Fatalf("test #%d: the path from %d to %d has %d steps, Dist: %d",
+						testInd, from, to, p.Len(), l.Dist(from, to))
+				}
+			}
+		}
+	}
+}
+
+// benchmarkFairLocatorWorkers builds the locator of the small benchmark map
+// with the pairs split between the workers.
+// The speedup over one worker needs as many CPUs as workers.
+func benchmarkFairLocatorWorkers(b *testing.B, workers int) {
+	b.StopTimer()
+	m := landmarkTestMap(locatorBenchTorus, 25, 1)
+	cells := landmarkTestCells(m)
+	if runtime.GOMAXPROCS(0) < workers {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(workers))
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		l := NewFairLocator(m, locatorBenchTorus.Size())
+		l.Workers = workers
+		l.Add(cells...)
+		l.Update(func() bool { return true })
+		l.stopWorkers()
+	}
+}
+
+func BenchmarkFairLocatorWorkers1(b *testing.B) {
+	benchmarkFairLocatorWorkers(b, 1)
+}
+
+func BenchmarkFairLocatorWorkers2(b *testing.B) {
+	benchmarkFairLocatorWorkers(b, 2)
+}
+
+func BenchmarkFairLocatorWorkers4(b *testing.B) {
+	benchmarkFairLocatorWorkers(b, 4)
+}
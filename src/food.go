@@ -0,0 +1,82 @@
+package main
+
+// Gathering knows where food can be gathered from.
+// Food can't be entered, it's gathered by ants within the spawn radius.
+type Gathering struct {
+	m       *Map
+	l       Locator
+	radius2 int
+	maskRow []int
+	maskCol []int
+}
+
+func NewGathering(m *Map, l Locator, spawnRadius2 int) *Gathering {
+	g := &Gathering{m: m, l: l, radius2: spawnRadius2}
+	g.maskRow, g.maskCol = GenerateMask(spawnRadius2)
+	return g
+}
+
+// InRange reports whether an ant at loc gathers the food.
+func (g *Gathering) InRange(loc, food Location) bool {
+	return g.m.T.Dist2(loc, food) <= g.radius2
+}
+
+// Zone returns the land squares which can be entered to gather the food.
+func (g *Gathering) Zone(food Location) (res []Location) {
+	items := g.m.Items[g.m.Turn()]
+	for i := range g.maskRow {
+		loc := g.m.T.ShiftLoc(food, g.maskRow[i], g.maskCol[i])
+		if loc == food || g.m.Terrain[loc] != Land {
+			continue
+		}
+		hasFood := false
+		for _, item := range items.At[loc] {
+			if item.What == Food {
+				hasFood = true
+			}
+		}
+		if !hasFood {
+			res = append(res, loc)
+		}
+	}
+	return
+}
+
+// Dist returns the distance to the closest square of the gathering zone and the square itself.
+// It's 0 if the food is already in range, and NoPath if the zone can't be reached.
+func (g *Gathering) Dist(from, food Location) (dist int, to Location) {
+	if g.InRange(from, food) {
+		return 0, from
+	}
+	dist = NoPath
+	for _, loc := range g.Zone(food) {
+		if d := g.l.Dist(from, loc); d != NoPath && d < dist {
+			dist = d
+			to = loc
+		}
+	}
+	return
+}
+
+func (g *Gathering) minDist(food Location, ants []Location) int {
+	best := NoPath
+	for _, ant := range ants {
+		if d, _ := g.Dist(ant, food); d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+// Score rates the food by the race to its gathering zone between my and enemy ants.
+func (g *Gathering) Score(food Location, mine, enemy []Location) int {
+	myDist := g.minDist(food, mine)
+	enemyDist := g.minDist(food, enemy)
+	switch {
+	case myDist < enemyDist:
+		return FoodScore
+	case myDist == enemyDist && myDist != NoPath:
+		return ContestedFoodScore
+	}
+	return LostFoodScore
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+)
+
+// manhattanLocator measures distances on the torus ignoring water.
+type manhattanLocator struct {
+	t Torus
+}
+
+func (l manhattanLocator) Dist(from, to Location) int {
+	dr := l.t.Row(from) - l.t.Row(to)
+	dc := l.t.Col(from) - l.t.Col(to)
+	if dr < 0 {
+		dr = -dr
+	}
+	if dc < 0 {
+		dc = -dc
+	}
+	if l.t.Rows-dr < dr {
+		dr = l.t.Rows - dr
+	}
+	if l.t.Cols-dc < dc {
+		dc = l.t.Cols - dc
+	}
+	return dr + dc
+}
+
+func TestGathering(t *testing.T) {
+	tt := Torus{20, 20}
+	m := NewMap(tt, 100)
+	m.Terrain[tt.Loc(5, 6)] = Water
+	m.Update([]Input{
+		{What: Hill, Row: 5, Col: 1, Owner: Me},
+		{What: Ant, Row: 5, Col: 1, Owner: Me},
+		{What: Food, Row: 5, Col: 5},
+		{What: Food, Row: 4, Col: 5},
+	})
+	g := NewGathering(m, manhattanLocator{tt}, 1)
+	food := tt.Loc(5, 5)
+
+	// Water and other food can't be entered
+	zone := g.Zone(food)
+	want := map[Location]bool{tt.Loc(6, 5): true, tt.Loc(5, 4): true}
+	if len(zone) != len(want) {
+		t.Fatalf("Zone: %v, want: %v", zone, want)
+	}
+	for _, loc := range zone {
+		if !want[loc] {
+			t.Errorf("Zone: %v, want: %v", zone, want)
+		}
+	}
+
+	if dist, to := g.Dist(tt.Loc(5, 1), food); dist != 3 || to != tt.Loc(5, 4) {
+		t.Errorf("Dist from (5, 1): %d, %d, want: 3, %d", dist, to, tt.Loc(5, 4))
+	}
+	if dist, to := g.Dist(tt.Loc(6, 5), food); dist != 0 || to != tt.Loc(6, 5) {
+		t.Errorf("Dist from (6, 5): %d, %d, want: 0, %d", dist, to, tt.Loc(6, 5))
+	}
+
+	tests := []struct {
+		Mine, Enemy []Location
+		Score       int
+	}{
+		{[]Location{tt.Loc(5, 1)}, nil, FoodScore},
+		{[]Location{tt.Loc(5, 1)}, []Location{tt.Loc(10, 5)}, FoodScore},
+		{[]Location{tt.Loc(5, 1)}, []Location{tt.Loc(9, 5)}, ContestedFoodScore},
+		{[]Location{tt.Loc(5, 1)}, []Location{tt.Loc(8, 5)}, LostFoodScore},
+	}
+	for i, test := range tests {
+		if got := g.Score(food, test.Mine, test.Enemy); got != test.Score {
+			t.Errorf("test #%d: Score: %d, want: %d", i, got, test.Score)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"os"
+)
+
+// Default number of landmarks of LandmarkLocator
+const DefaultLandmarks = 16
+
+// LandmarkLocator finds exact distances with A* search, guided by the lower bounds
+// from the distances to a few landmark cells (the ALT method).
+// The memory is linear in the number of cells: a distance field per landmark,
+// and one more field for the last target. Only one target is cached on purpose:
+// PathFinder asks for the distances from every step of a path to one target
+// in a row, and a field per target would cost as much memory as FairLocator.
+// Queries alternating between targets run A* every time.
+type LandmarkLocator struct {
+	conn         Connector
+	known        []bool
+	cells        []Location
+	version      int // incremented when cells are added
+	Landmarks    []Location
+	MaxLandmarks int
+	fields       [][]int16 // distance+1 from the landmark, 0 if unreachable
+	fieldVer     []int     // version the field has been computed for
+
+	// the field of the last target
+	lastTo     Location
+	toField    []int16
+	toFieldVer int
+
+	// search state
+	g       []int
+	stamp   []int
+	closed  []int
+	cur     int
+	buckets [][]Location
+	q       []Location
+}
+
+func NewLandmarkLocator(conn Connector, size int) *LandmarkLocator {
+	return &LandmarkLocator{
+		conn:         conn,
+		known:        make([]bool, size),
+		MaxLandmarks: DefaultLandmarks,
+		g:            make([]int, size),
+		stamp:        make([]int, size),
+		closed:       make([]int, size),
+		lastTo:       -1,
+		toFieldVer:   -1,
+	}
+}
+
+// Add adds new cells. Landmarks are updated by Update.
+func (l *LandmarkLocator) Add(locs ...Location) os.Error {
+	for _, loc := range locs {
+		if l.known[loc] {
+			continue
+		}
+		l.known[loc] = true
+		l.cells = append(l.cells, loc)
+		l.version++
+	}
+	return nil
+}
+
+// NeedUpdate reports whether some landmark is stale or missing.
+func (l *LandmarkLocator) NeedUpdate() bool {
+	for _, ver := range l.fieldVer {
+		if ver != l.version {
+			return true
+		}
+	}
+	return len(l.Landmarks) < l.MaxLandmarks && len(l.Landmarks) < len(l.cells)
+}
+
+// UpdateStep recomputes a stale landmark, or chooses a new one.
+func (l *LandmarkLocator) UpdateStep() {
+	for k, ver := range l.fieldVer {
+		if ver != l.version {
+			l.bfs(l.Landmarks[k], l.fields[k])
+			l.fieldVer[k] = l.version
+			return
+		}
+	}
+	if len(l.Landmarks) >= l.MaxLandmarks || len(l.Landmarks) >= len(l.cells) {
+		return
+	}
+	// The cell farthest from the landmarks, unreachable cells go first
+	next := l.cells[0]
+	best := -1
+	for _, loc := range l.cells {
+		d := NoPath
+		for _, field := range l.fields {
+			if field[loc] > 0 && int(field[loc])-1 < d {
+				d = int(field[loc]) - 1
+			}
+		}
+		if d > best {
+			best = d
+			next = loc
+		}
+	}
+	if best == 0 {
+		// All cells are landmarks
+		return
+	}
+	field := make([]int16, len(l.known))
+	l.bfs(next, field)
+	l.Landmarks = append(l.Landmarks, next)
+	l.fields = append(l.fields, field)
+	l.fieldVer = append(l.fieldVer, l.version)
+}
+
+func (l *LandmarkLocator) Update(ok func() bool) {
+	for l.NeedUpdate() && ok() {
+		l.UpdateStep()
+	}
+}
+
+// bfs fills the field with distances+1 from the cell.
+func (l *LandmarkLocator) bfs(from Location, field []int16) {
+	for i := range field {
+		field[i] = 0
+	}
+	field[from] = 1
+	l.q = append(l.q[:0], from)
+	for i := 0; i < len(l.q); i++ {
+		loc := l.q[i]
+		for _, conn := range l.conn.Conn(loc) {
+			if l.known[conn] && field[conn] == 0 {
+				field[conn] = field[loc] + 1
+				l.q = append(l.q, conn)
+			}
+		}
+	}
+}
+
+// Bounds returns the lower and the upper bounds of the distance known from the landmarks.
+// The lower bound is NoPath if the cells are not connected, the upper one is NoPath if unknown.
+func (l *LandmarkLocator) Bounds(from, to Location) (lower, upper int) {
+	upper = NoPath
+	for k, field := range l.fields {
+		if l.fieldVer[k] != l.version {
+			continue
+		}
+		a, b := int(field[from]), int(field[to])
+		if a == 0 && b == 0 {
+			continue
+		}
+		if a == 0 || b == 0 {
+			return NoPath, NoPath
+		}
+		if d := a - b; d > lower {
+			lower = d
+		} else if -d > lower {
+			lower = -d
+		}
+		if a+b-2 < upper {
+			upper = a + b - 2
+		}
+	}
+	return
+}
+
+// Dist returns the distance between the cells, or NoPath if they are not connected.
+// The first query to a target runs A*; the next one to the same target
+// fills the field of the target, which answers the queries until the target changes.
+func (l *LandmarkLocator) Dist(from, to Location) int {
+	if from == to {
+		return 0
+	}
+	if !l.known[from] || !l.known[to] {
+		return NoPath
+	}
+	if l.lastTo == to {
+		if l.toFieldVer != l.version {
+			if l.toField == nil {
+				l.toField = make([]int16, len(l.known))
+			}
+			l.bfs(to, l.toField)
+			l.toFieldVer = l.version
+		}
+		if d := l.toField[from]; d > 0 {
+			return int(d) - 1
+		}
+		return NoPath
+	}
+	l.lastTo = to
+	l.toFieldVer = -1
+	return l.search(from, to)
+}
+
+// search is A* with buckets by the estimated length of the path.
+func (l *LandmarkLocator) search(from, to Location) int {
+	h0, _ := l.Bounds(from, to)
+	if h0 == NoPath {
+		return NoPath
+	}
+	l.cur++
+	for i := range l.buckets {
+		l.buckets[i] = l.buckets[i][:0]
+	}
+	push := func(loc Location, g int) bool {
+		h, _ := l.Bounds(loc, to)
+		if h == NoPath {
+			return false
+		}
+		f := g + h - h0
+		for len(l.buckets) <= f {
+			l.buckets = append(l.buckets, nil)
+		}
+		l.buckets[f] = append(l.buckets[f], loc)
+		return true
+	}
+	l.g[from] = 0
+	l.stamp[from] = l.cur
+	push(from, 0)
+	for f := 0; f < len(l.buckets); f++ {
+		for i := 0; i < len(l.buckets[f]); i++ {
+			loc := l.buckets[f][i]
+			if l.closed[loc] == l.cur {
+				continue
+			}
+			l.closed[loc] = l.cur
+			if loc == to {
+				return l.g[loc]
+			}
+			for _, conn := range l.conn.Conn(loc) {
+				if !l.known[conn] {
+					continue
+				}
+				g := l.g[loc] + 1
+				if l.stamp[conn] == l.cur && l.g[conn] <= g {
+					continue
+				}
+				l.stamp[conn] = l.cur
+				l.g[conn] = g
+				push(conn, g)
+			}
+		}
+	}
+	return NoPath
+}
+
+// MemoryBytes estimates the memory used by distances.
+func (l *LandmarkLocator) MemoryBytes() int {
+	n := len(l.known)
+	return (len(l.fields)+1)*n*2 + n*(1+3*4)
+}
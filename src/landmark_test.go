@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"rand"
+	"testing"
+)
+
+// landmarkTestMap returns a map with the terrain of a generated game map.
+func landmarkTestMap(t Torus, waterPercent int, seed int64) *Map {
+	m := NewMap(t, 1)
+	copy(m.Terrain, GenerateGameMap(t, 2, waterPercent, seed).Terrain)
+	return m
+}
+
+func landmarkTestCells(m *Map) (res []Location) {
+	for loc, terrain := range m.Terrain {
+		if terrain == Land {
+			res = append(res, Location(loc))
+		}
+	}
+	return
+}
+
+// bfsDist returns the distances from the cell through the known cells.
+func bfsDist(conn Connector, known []bool, from Location) []int {
+	dist := make([]int, len(known))
+	for i := range dist {
+		dist[i] = NoPath
+	}
+	dist[from] = 0
+	q := []Location{from}
+	for len(q) > 0 {
+		loc := q[0]
+		q = q[1:]
+		for _, next := range conn.Conn(loc) {
+			if known[next] && dist[next] == NoPath {
+				dist[next] = dist[loc] + 1
+				q = append(q, next)
+			}
+		}
+	}
+	return dist
+}
+
+var landmarkTests = []struct {
+	rows, cols   int
+	waterPercent int
+	seed         int64
+	landmarks    int
+}{
+	{10, 10, 0, 1, 4},
+	{20, 30, 20, 2, 8},
+	{30, 30, 35, 3, 16},
+	{40, 20, 45, 4, 16},
+	{30, 30, 35, 5, 0},
+}
+
+func TestLandmarkLocator(t *testing.T) {
+	for testInd, test := range landmarkTests {
+		tt := Torus{test.rows, test.cols}
+		m := landmarkTestMap(tt, test.waterPercent, test.seed)
+		rnd := rand.New(rand.NewSource(test.seed))
+		cells := landmarkTestCells(m)
+		for i := range cells {
+			j := rnd.Intn(i + 1)
+			cells[i], cells[j] = cells[j], cells[i]
+		}
+		l := NewLandmarkLocator(m, tt.Size())
+		l.MaxLandmarks = test.landmarks
+		known := make([]bool, tt.Size())
+		// Cells are added in two halves, the second one makes the landmarks stale
+		for _, part := range [][]Location{cells[:len(cells)/2], cells[len(cells)/2:]} {
+			l.Add(part...)
+			for _, loc := range part {
+				known[loc] = true
+			}
+			for _, update := range []bool{false, true} {
+				if update {
+					l.Update(func() bool { return true })
+					if len(l.Landmarks) != test.landmarks {
+						t.Errorf("test #%d: %d landmarks, want: %d", testInd, len(l.Landmarks), test.landmarks)
+					}
+				}
+				for k := 0; k < 20; k++ {
+					to := part[rnd.Intn(len(part))]
+					want := bfsDist(m, known, to)
+					for q := 0; q < 10; q++ {
+						from := cells[rnd.Intn(len(cells))]
+						if got := l.Dist(from, to); got != want[from] {
+							t.Fatalf("test #%d: Dist(%d, %d) = %d, want: %d", testInd, from, to, got, want[from])
+						}
+						if !update {
+							continue
+						}
+						lower, upper := l.Bounds(from, to)
+						if lower > want[from] || upper < want[from] {
+							t.Fatalf("test #%d: Bounds(%d, %d) = %d, %d, the distance %d is out of them",
+								testInd, from, to, lower, upper, want[from])
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// bfsOrder returns the cells reachable from the cell in the order of the distance.
+func bfsOrder(conn Connector, known []bool, from Location) []Location {
+	seen := make([]bool, len(known))
+	seen[from] = true
+	q := []Location{from}
+	for i := 0; i < len(q); i++ {
+		for _, next := range conn.Conn(q[i]) {
+			if known[next] && !seen[next] {
+				seen[next] = true
+				q = append(q, next)
+			}
+		}
+	}
+	return q
+}
+
+// Locator benchmarks run on maps with a quarter of water: a small one,
+// where FairLocator indexes all cells, and a large one of the size of the biggest game maps,
+// where FairLocator indexes only the cells closest to a start cell within largeFairBytes,
+// like the bot discovers the map.
+var locatorBenchTorus = Torus{48, 48}
+var largeLocatorBenchTorus = Torus{200, 200}
+
+const largeFairBytes = 10 * 1000 * 1000
+
+func newBenchLocator(name string, t Torus) (*Map, DynamicLocator, []Location) {
+	m := landmarkTestMap(t, 25, 1)
+	l, _ := NewLocator(name, m, t.Size())
+	known := make([]bool, t.Size())
+	cells := landmarkTestCells(m)
+	for _, loc := range cells {
+		known[loc] = true
+	}
+	cells = bfsOrder(m, known, cells[0])
+	if fl, ok := l.(*FairLocator); ok && t == largeLocatorBenchTorus {
+		fl.MaxBytes = largeFairBytes
+	}
+	l.Add(cells...)
+	l.Update(func() bool { return true })
+	return m, l, cells
+}
+
+func benchmarkLocatorBuild(b *testing.B, name string, t Torus) {
+	for i := 0; i < b.N; i++ {
+		newBenchLocator(name, t)
+	}
+}
+
+// benchmarkLocatorDist queries random pairs, ten to each target,
+// like PathFinder does.
+func benchmarkLocatorDist(b *testing.B, name string, t Torus) {
+	b.StopTimer()
+	_, l, cells := newBenchLocator(name, t)
+	rnd := rand.New(rand.NewSource(1))
+	b.StartTimer()
+	var to Location
+	for i := 0; i < b.N; i++ {
+		if i%10 == 0 {
+			to = cells[rnd.Intn(len(cells))]
+		}
+		l.Dist(cells[rnd.Intn(len(cells))], to)
+	}
+}
+
+func BenchmarkFairLocatorBuild(b *testing.B) {
+	benchmarkLocatorBuild(b, "fair", locatorBenchTorus)
+}
+
+func BenchmarkLandmarkLocatorBuild(b *testing.B) {
+	benchmarkLocatorBuild(b, "landmark", locatorBenchTorus)
+}
+
+func BenchmarkFairLocatorDist(b *testing.B) {
+	benchmarkLocatorDist(b, "fair", locatorBenchTorus)
+}
+
+func BenchmarkLandmarkLocatorDist(b *testing.B) {
+	benchmarkLocatorDist(b, "landmark", locatorBenchTorus)
+}
+
+func BenchmarkFairLocatorBuildLarge(b *testing.B) {
+	benchmarkLocatorBuild(b, "fair", largeLocatorBenchTorus)
+}
+
+func BenchmarkLandmarkLocatorBuildLarge(b *testing.B) {
+	benchmarkLocatorBuild(b, "landmark", largeLocatorBenchTorus)
+}
+
+func BenchmarkFairLocatorDistLarge(b *testing.B) {
+	benchmarkLocatorDist(b, "fair", largeLocatorBenchTorus)
+}
+
+func BenchmarkLandmarkLocatorDistLarge(b *testing.B) {
+	benchmarkLocatorDist(b, "landmark", largeLocatorBenchTorus)
+}
+
+// TestLocatorComparison checks both locators against BFS on the benchmark maps
+// and logs a row per map and locator (go test -v):
+// the memory, the share of pairs answered with a wrong distance, which are
+// the pairs with a cell not indexed by FairLocator, and the mean and max
+// errors of the lower bounds of LandmarkLocator relative to the distances.
+// On the machine it's been written on the rows were:
+//
+//	map      locator  cells  bytes      wrong  mean err  max err
+//	48x48    fair     1679   3154944    0.0%
+//	48x48    landmark 1679   108288     0.0%   6.7%     50.0%
+//	200x200  fair     2508   6451456    99.5%
+//	200x200  landmark 29782  1880000    0.0%   7.5%     41.0%
+func TestLocatorComparison(t *testing.T) {
+	if testing.Short() {
+		return
+	}
+	t.Logf("map      locator  cells  bytes      wrong  mean err  max err")
+	for _, tt := range []Torus{locatorBenchTorus, largeLocatorBenchTorus} {
+		m, fair, cells := newBenchLocator("fair", tt)
+		_, landmark, _ := newBenchLocator("landmark", tt)
+		lm := landmark.(*LandmarkLocator)
+		rnd := rand.New(rand.NewSource(2))
+		known := make([]bool, tt.Size())
+		for _, loc := range cells {
+			known[loc] = true
+		}
+		var fairWrong, landmarkWrong, pairs int
+		var errSum, errMax float64
+		for k := 0; k < 20; k++ {
+			to := cells[rnd.Intn(len(cells))]
+			want := bfsDist(m, known, to)
+			for q := 0; q < 200; q++ {
+				from := cells[rnd.Intn(len(cells))]
+				if from == to {
+					continue
+				}
+				pairs++
+				if fair.Dist(from, to) != want[from] {
+					fairWrong++
+				}
+				if got := landmark.Dist(from, to); got != want[from] {
+					t.Errorf("LandmarkLocator.Dist(%d, %d) = %d, want: %d", from, to, got, want[from])
+					landmarkWrong++
+				}
+				lower, _ := lm.Bounds(from, to)
+				e := 1 - float64(lower)/float64(want[from])
+				errSum += e
+				if e > errMax {
+					errMax = e
+				}
+			}
+		}
+		fl := fair.(*FairLocator)
+		if !fl.full && fairWrong > 0 {
+			t.Errorf("%dx%d: FairLocator indexes all cells, but answers %d pairs wrong", tt.Rows, tt.Cols, fairWrong)
+		}
+		name := fmt.Sprintf("%dx%d", tt.Rows, tt.Cols)
+		t.Logf("%-8s fair     %-6d %-10d %.1f%%", name, len(fl.ind2loc), fl.MemoryBytes(),
+			100*float64(fairWrong)/float64(pairs))
+		t.Logf("%-8s landmark %-6d %-10d %.1f%%   %.1f%%     %.1f%%", name, len(cells), lm.MemoryBytes(),
+			100*float64(landmarkWrong)/float64(pairs), 100*errSum/float64(pairs), 100*errMax)
+	}
+}
@@ -1,10 +1,46 @@
 package main
 
 import (
+	"flag"
 	"log"
 )
 
+var dumpMap = flag.String("dump_map", "", "write the discovered map to this file at the end of the game")
+var replayFile = flag.String("replay", "", "record the game into this file")
+
 func main() {
+	flag.Parse()
+	if *engineMode {
+		if err := Play(flag.Args()); err != nil {
+			log.Panicf("Play: %v", err)
+		}
+		return
+	}
+	if *rerunInput != "" {
+		// The background update depends on the timing, the reruns would not be reproducible
+		explicit := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "background_locator" {
+				explicit = true
+			}
+		})
+		if !explicit {
+			*backgroundLocator = false
+		}
+		replay, err := Rerun(new(MyBot), *rerunInput, *rerunBaseline)
+		if replay != nil && *replayFile != "" {
+			if err := replay.WriteFile(*replayFile); err != nil {
+				log.Printf("replay.WriteFile: %v", err)
+			}
+		}
+		if err != nil {
+			log.Fatalf("Rerun: %v", err)
+		}
+		return
+	}
+	if *replayFile != "" {
+		stdConn.Replay = NewReplay()
+	}
 	var p Params
 	p, err := ReadParams()
 	if err != nil {
@@ -14,7 +50,18 @@ func main() {
 	if err = bot.Init(p); err != nil {
 		log.Panicf("bot.Init: %v", err)
 	}
-	if err := Loop(p, bot); err != nil {
+	err = Loop(p, bot)
+	if stdConn.Replay != nil {
+		if err := stdConn.Replay.WriteFile(*replayFile); err != nil {
+			log.Printf("replay.WriteFile: %v", err)
+		}
+	}
+	if err != nil {
 		log.Panicf("Loop: %s", err)
 	}
+	if *dumpMap != "" {
+		if err := bot.WriteMap(*dumpMap); err != nil {
+			log.Printf("WriteMap: %v", err)
+		}
+	}
 }
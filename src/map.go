@@ -71,6 +71,15 @@ func (it *Items) HasAntAt(loc Location, owner int) bool {
 	return false
 }
 
+func (it *Items) HasDeadAntAt(loc Location, owner int) bool {
+	for _, item := range it.At[loc] {
+		if item.What == DeadAnt && item.Owner == owner {
+			return true
+		}
+	}
+	return false
+}
+
 func (it *Items) HasMyHillAt(loc Location) bool {
 	for _, item := range it.At[loc] {
 		if item.What == Hill && item.Owner == Me {
@@ -89,6 +98,12 @@ type MyAnt struct {
 	Path   Path
 	Target Location
 	Score  int
+	// Hold keeps the ant in place on the current turn, it does not take paths of other ants
+	Hold bool
+
+	// The view of the ant is counted in Map from this location
+	viewLoc Location
+	viewing bool
 }
 
 func (a *MyAnt) Loc(turn int) Location {
@@ -101,7 +116,8 @@ func (a *MyAnt) HasLoc(turn int) bool {
 }
 
 func (a *MyAnt) NewTurn(turn int) {
-	if !a.HasLoc(turn) {
+	// There could be more than one turn missed, if the previous turn has failed
+	for !a.HasLoc(turn) {
 		a.Locs = append(a.Locs, a.Locs[len(a.Locs)-1])
 	}
 }
@@ -150,6 +166,18 @@ type Map struct {
 	LastVisited     []int
 	Next            *Items
 	NewCells        []Location
+	NewWater        []Location // land cells which turned out to be water on this turn
+	ViewRadius2     int
+	viewDeltas      map[Direction]*viewDelta
+	viewCount       []int // number of my ants seeing the cell now
+	lastSeen        []int
+	died            []*MyAnt // my ants died on the current turn
+	Mem             *Memory
+	claimed         LocSet
+	// StayOnHill tells which ants may stay on my hill, nil means none.
+	// An ant is moved off anyway, if the hill has been blocked on the previous turn.
+	StayOnHill   func(ant *MyAnt) bool
+	blockedHills LocSet
 }
 
 func NewMap(t Torus, viewRadius2 int) (m *Map) {
@@ -158,8 +186,14 @@ func NewMap(t Torus, viewRadius2 int) (m *Map) {
 		Terrain:         make([]Terrain, t.Size()),
 		Items:           []*Items{NewItems(t)},
 		LastVisited:     make([]int, t.Size()),
+		viewCount:       make([]int, t.Size()),
+		lastSeen:        make([]int, t.Size()),
 		MyLiveAntsIndex: NewMyAntIndex(t.Size()),
+		ViewRadius2:     viewRadius2,
+		claimed:         NewLocSet(t.Size()),
+		blockedHills:    NewLocSet(t.Size()),
 	}
+	m.Mem = NewMemory(m)
 	m.GenerateViewMask(viewRadius2)
 	return m
 }
@@ -204,48 +238,98 @@ func (m *Map) EnemyHills() (hills []*Item) {
 	return
 }
 
+// UpdateLiveAnts matches my ants and their moves made on the previous turn
+// with my ants seen on the current turn.
 func (m *Map) UpdateLiveAnts() {
+	turn := m.Turn()
+	items := m.Items[turn]
 	m.MyLiveAntsIndex.Clear()
-	// Find dead ants and remove them from MyLiveAnts
-	var dead []int
-	items := m.Items[m.Turn()]
+	m.claimed.Clear()
+
+	// Confirm the moves. Two ants never want the same cell,
+	// and no ant wants a cell of another one which stays, see ResolveConflicts.
+	var unconfirmed []int
 	for i, ant := range m.MyLiveAnts {
-		ant.NewTurn(m.Turn())
-		if !items.HasAntAt(ant.Loc(m.Turn()), Me) {
-			dead = append(dead, i)
+		ant.NewTurn(turn)
+		to := ant.Loc(turn)
+		if !items.HasAntAt(to, Me) || m.claimed.Has(to) {
+			unconfirmed = append(unconfirmed, i)
+			continue
+		}
+		m.claimed.Add(to)
+		if ant.Loc(turn-1) != to && ant.Path != nil {
+			ant.Path.Advance(1)
+		}
+	}
+
+	// Find ants which have failed to move, and dead ants
+	var dead []int
+	for _, i := range unconfirmed {
+		ant := m.MyLiveAnts[i]
+		to := ant.Loc(turn)
+		from := ant.Loc(turn - 1)
+		if from != to && !items.HasDeadAntAt(to, Me) &&
+			items.HasAntAt(from, Me) && !m.claimed.Has(from) {
+			fmt.Fprintf(os.Stderr, "The move of ant %v has failed, it's still at %d\n", ant, from)
+			m.claimed.Add(from)
+			ant.Locs[turn-ant.BornAt] = from
+			continue
 		}
-		m.MyLiveAntsIndex.Add(ant.Loc(m.Turn()), ant)
+		dead = append(dead, i)
 	}
+	m.died = m.died[:0]
 	for i := len(dead) - 1; i >= 0; i-- {
+		m.died = append(m.died, m.MyLiveAnts[dead[i]])
 		m.MyLiveAnts[dead[i]].Alive = false
-		m.MyLiveAnts[dead[i]].DiedAt = m.Turn()
+		m.MyLiveAnts[dead[i]].DiedAt = turn
 		m.MyLiveAnts[dead[i]] = m.MyLiveAnts[len(m.MyLiveAnts)-1]
 		m.MyLiveAnts = m.MyLiveAnts[:len(m.MyLiveAnts)-1]
 	}
+	for _, ant := range m.MyLiveAnts {
+		m.MyLiveAntsIndex.Add(ant.Loc(turn), ant)
+	}
 
-	// Find newly born ants. They born in hills
-	for _, hill := range m.MyHills() {
-		if items.HasAntAt(hill.Loc, Me) &&
-			m.MyLiveAntAt(hill.Loc) == nil {
-			ant := &MyAnt{
-				BornAt: m.Turn(),
-				Alive:  true,
-				Locs:   []Location{hill.Loc},
-			}
-			m.MyAnts = append(m.MyAnts, ant)
-			m.MyLiveAnts = append(m.MyLiveAnts, ant)
-			m.MyLiveAntsIndex.Add(ant.Loc(m.Turn()), ant)
+	// The rest of my ants are newly born on hills,
+	// or the ants we have lost track of.
+	for _, item := range items.All {
+		if item.What != Ant || item.Owner != Me || m.claimed.Has(item.Loc) {
+			continue
 		}
+		if !items.HasMyHillAt(item.Loc) {
+			fmt.Fprintf(os.Stderr, "Unknown ant at %d is adopted\n", item.Loc)
+		}
+		ant := &MyAnt{
+			BornAt: turn,
+			Alive:  true,
+			Locs:   []Location{item.Loc},
+		}
+		m.MyAnts = append(m.MyAnts, ant)
+		m.MyLiveAnts = append(m.MyLiveAnts, ant)
+		m.MyLiveAntsIndex.Add(ant.Loc(turn), ant)
 	}
+}
 
+// Resync forgets the moves of the current turn, because they have not been sent.
+func (m *Map) Resync() {
+	for _, ant := range m.MyLiveAnts {
+		if ant.HasLoc(m.Turn()) {
+			ant.Locs = ant.Locs[:m.Turn()-ant.BornAt+1]
+		}
+		ant.Path = nil
+		ant.Hold = false
+	}
 }
 
 func (m *Map) Update(input []Input) {
 	m.Items = append(m.Items, NewItems(m.T))
+	m.NewWater = m.NewWater[:0]
 	for _, in := range input {
 		loc := m.T.Loc(in.Row, in.Col)
 		switch in.What {
 		case Water:
+			if m.Terrain[loc] == Land {
+				m.NewWater = append(m.NewWater, loc)
+			}
 			m.Terrain[loc] = Water
 		case Hill:
 			fallthrough
@@ -266,42 +350,148 @@ func (m *Map) Update(input []Input) {
 	m.UpdateLiveAnts()
 	m.UpdateVisibility()
 	m.UpdateLastVisited()
+	m.Mem.Update()
+}
+
+// viewDelta has the offsets of cells which enter the view of an ant
+// making a step (relative to the new location), and cells which leave it
+// (relative to the old location).
+type viewDelta struct {
+	enterRow, enterCol []int
+	leaveRow, leaveCol []int
 }
 
 func (m *Map) GenerateViewMask(viewRadius2 int) {
-	for i := 0; i*i <= viewRadius2; i++ {
-		for j := 0; j*j+i*i <= viewRadius2; j++ {
-			m.ViewMaskRow = append(m.ViewMaskRow, i)
-			m.ViewMaskCol = append(m.ViewMaskCol, j)
+	m.ViewMaskRow, m.ViewMaskCol = GenerateMask(viewRadius2)
+
+	// Offsets are small, so they can be packed into one int
+	const k = 1 << 10
+	inMask := make(map[int]bool)
+	for i := range m.ViewMaskRow {
+		inMask[m.ViewMaskRow[i]*k+m.ViewMaskCol[i]] = true
+	}
+	m.viewDeltas = make(map[Direction]*viewDelta)
+	for _, dir := range Dirs {
+		var dr, dc int
+		switch dir {
+		case North:
+			dr = -1
+		case South:
+			dr = 1
+		case West:
+			dc = -1
+		case East:
+			dc = 1
+		}
+		d := new(viewDelta)
+		for i := range m.ViewMaskRow {
+			row, col := m.ViewMaskRow[i], m.ViewMaskCol[i]
+			if !inMask[(row+dr)*k+col+dc] {
+				d.enterRow = append(d.enterRow, row)
+				d.enterCol = append(d.enterCol, col)
+			}
+			if !inMask[(row-dr)*k+col-dc] {
+				d.leaveRow = append(d.leaveRow, row)
+				d.leaveCol = append(d.leaveCol, col)
+			}
+		}
+		m.viewDeltas[dir] = d
+	}
+}
+
+// GenerateMask returns row and column offsets of all cells
+// within the given squared radius from the origin, including the origin itself.
+func GenerateMask(radius2 int) (maskRow, maskCol []int) {
+	for i := 0; i*i <= radius2; i++ {
+		for j := 0; j*j+i*i <= radius2; j++ {
+			maskRow = append(maskRow, i)
+			maskCol = append(maskCol, j)
 			if i > 0 {
-				m.ViewMaskRow = append(m.ViewMaskRow, -i)
-				m.ViewMaskCol = append(m.ViewMaskCol, j)
+				maskRow = append(maskRow, -i)
+				maskCol = append(maskCol, j)
 			}
 			if j > 0 {
-				m.ViewMaskRow = append(m.ViewMaskRow, i)
-				m.ViewMaskCol = append(m.ViewMaskCol, -j)
+				maskRow = append(maskRow, i)
+				maskCol = append(maskCol, -j)
 			}
 			if i > 0 && j > 0 {
-				m.ViewMaskRow = append(m.ViewMaskRow, -i)
-				m.ViewMaskCol = append(m.ViewMaskCol, -j)
+				maskRow = append(maskRow, -i)
+				maskCol = append(maskCol, -j)
 			}
 		}
 	}
+	return
+}
+
+// addView changes the number of ants seeing the cells at the offsets from loc.
+// Cells entering the view for the first time are discovered.
+func (m *Map) addView(loc Location, maskRow, maskCol []int, delta int) {
+	for i := range maskRow {
+		loc2 := m.T.ShiftLoc(loc, maskRow[i], maskCol[i])
+		m.viewCount[loc2] += delta
+		if delta < 0 && m.viewCount[loc2] == 0 {
+			// It has been seen on the previous turn
+			m.lastSeen[loc2] = m.Turn() - 1
+		}
+		if delta > 0 && m.Terrain[loc2] == Unknown {
+			m.Terrain[loc2] = Land
+			m.NewCells = append(m.NewCells, loc2)
+		}
+	}
 }
 
+// UpdateVisibility moves the view of every ant from the location
+// it has been counted at. For ants made one step, only the cells
+// entering and leaving the view are updated.
 func (m *Map) UpdateVisibility() {
 	m.NewCells = m.NewCells[:0]
+	for _, ant := range m.died {
+		if ant.viewing {
+			m.addView(ant.viewLoc, m.ViewMaskRow, m.ViewMaskCol, -1)
+			ant.viewing = false
+		}
+	}
 	for _, ant := range m.MyLiveAnts {
-		for i := 0; i < len(m.ViewMaskRow); i++ {
-			loc2 := m.T.ShiftLoc(ant.Loc(m.Turn()), m.ViewMaskRow[i], m.ViewMaskCol[i])
-			if m.Terrain[loc2] == Unknown {
-				m.Terrain[loc2] = Land
-				m.NewCells = append(m.NewCells, loc2)
+		loc := ant.Loc(m.Turn())
+		if ant.viewing && ant.viewLoc == loc {
+			continue
+		}
+		stepped := false
+		if ant.viewing {
+			for _, dir := range Dirs {
+				if m.T.NewLoc(ant.viewLoc, dir) == loc {
+					d := m.viewDeltas[dir]
+					m.addView(ant.viewLoc, d.leaveRow, d.leaveCol, -1)
+					m.addView(loc, d.enterRow, d.enterCol, 1)
+					stepped = true
+					break
+				}
 			}
+			if !stepped {
+				m.addView(ant.viewLoc, m.ViewMaskRow, m.ViewMaskCol, -1)
+			}
+		}
+		if !stepped {
+			m.addView(loc, m.ViewMaskRow, m.ViewMaskCol, 1)
 		}
+		ant.viewLoc = loc
+		ant.viewing = true
 	}
 }
 
+// Visible reports whether the location is seen by my ants on the current turn.
+func (m *Map) Visible(loc Location) bool {
+	return m.viewCount[loc] > 0
+}
+
+// LastSeen returns the last turn the location has been seen, or 0 if never.
+func (m *Map) LastSeen(loc Location) int {
+	if m.Visible(loc) {
+		return m.Turn()
+	}
+	return m.lastSeen[loc]
+}
+
 func (m *Map) UpdateLastVisited() {
 	for _, ant := range m.MyLiveAnts {
 		m.LastVisited[ant.Loc(m.Turn())] = m.Turn()
@@ -344,123 +534,246 @@ func (m *Map) LandNeighbours(loc Location) (res []Location) {
 	return
 }
 
-func (m *Map) MoveAnts() {
-	m.ResolveConflicts()
-	//	fmt.Fprintf(os.Stderr, "MyLiveAnts: %v\n", m.MyLiveAnts)
-	for _, ant := range m.MyLiveAnts {
-		if ant.Path == nil {
-			continue
+func (m *Map) MoveAnts(deadline *Deadline) {
+	m.ClearHills()
+	dirs := m.ResolveConflicts(deadline)
+	for i, ant := range m.MyLiveAnts {
+		if dirs[i] != 0 {
+			// The path is advanced when the move is confirmed by the next update
+			m.Move(ant, dirs[i])
 		}
-		dir := ant.Path.Dir(0)
-		if m.CanMove(ant.Loc(m.Turn()), dir) {
-			m.Move(ant, dir)
-			ant.Path.Advance(1)
-		} else {
-			//			fmt.Fprintf(os.Stderr, "Can't move, dir: %c, ant loc: %d\n", dir, ant.Loc(m.Turn()))
+		ant.Hold = false
+	}
+
+	m.blockedHills.Clear()
+	for _, hill := range m.MyHills() {
+		if ant := m.MyLiveAntAt(hill.Loc); ant != nil && !ant.HasLoc(m.Turn()+1) {
+			m.blockedHills.Add(hill.Loc)
 		}
 	}
 }
 
-func (m *Map) Conn(loc Location) (res []Location) {
-	return m.LandNeighbours(loc)
+// canStep reports whether the square can be entered, if my ants there move away.
+func (m *Map) canStep(to Location) bool {
+	if m.Terrain[to] == Water {
+		return false
+	}
+	for _, item := range m.Items[m.Turn()].At[to] {
+		if item.What == Food || item.What == Ant && item.Owner != Me {
+			return false
+		}
+	}
+	return true
 }
 
-func (m *Map) ResolveConflicts() {
-	q := make([]*MyAnt, len(m.MyLiveAnts))
-	copy(q, m.MyLiveAnts)
-	var q2 []*MyAnt
-	for len(q) > 0 {
-		tmpQ := q2
-		q2 = q
-		q = tmpQ[:0]
-
-		for _, ant := range q2 {
-			//			fmt.Fprintf(os.Stderr, "ResolveConflicts for ant at %v, ", ant.Loc(m.Turn()))
-			if ant.Path == nil {
-				//				fmt.Fprintf(os.Stderr, "Path == nil\n")
+// ClearHills gives a step off my hill to my ants standing there without a possible move,
+// so new ants can spawn. The step is made with a high priority, see ResolveConflicts.
+// If the hill has been blocked on the previous turn, the ant is moved off this way
+// even if it has a path or is allowed to stay, so it can't lose the move to another ant.
+func (m *Map) ClearHills() {
+	for _, hill := range m.MyHills() {
+		ant := m.MyLiveAntAt(hill.Loc)
+		if ant == nil {
+			continue
+		}
+		if !m.blockedHills.Has(hill.Loc) {
+			if ant.Path != nil && ant.Path.Len() > 0 && m.canStep(m.T.NewLoc(hill.Loc, ant.Path.Dir(0))) {
 				continue
 			}
-			if ant.Path.Len() == 0 {
-				//				fmt.Fprintf(os.Stderr, "Path is empty\n")
-				ant.Path = nil
+			if m.StayOnHill != nil && m.StayOnHill(ant) {
 				continue
 			}
-			dir := ant.Path.Dir(0)
-			//			fmt.Fprintf(os.Stderr, "dir = %c ", dir)
-			to := m.T.NewLoc(ant.Loc(m.Turn()), dir)
-			ant2 := m.MyLiveAntAt(to)
-			if ant == ant2 {
-				panic(fmt.Sprintf("ant == ant2! loc: %d, newLoc: %d, dir: %c", ant.Loc(m.Turn()), to, dir))
-			}
-			if ant2 == nil {
-				//				fmt.Fprintf(os.Stderr, "ant2 == nil\n")
-				// FIXME: do something with the case when two ants want to enter one cell
+		}
+		// A free square is the best, a square of my moving ant is the next one
+		var best Direction
+		bestRank := 0
+		for _, dir := range Dirs {
+			to := m.T.NewLoc(hill.Loc, dir)
+			if !m.canStep(to) || m.HasMyHillAt(to) {
 				continue
 			}
-			if ant2.Path != nil && ant2.Path.Len() == 0 {
-				ant2.Path = nil
-			}
-			if ant2.Path == nil {
-				//				fmt.Fprintf(os.Stderr, "ant2.Path == nil. Success\n")
-				tmpPath := ant.Path
-				ant.Path = ant2.Path
-				ant2.Path = tmpPath
-				tmpTarget := ant.Target
-				ant.Target = ant2.Target
-				ant2.Target = tmpTarget
-				tmpScore := ant.Score
-				ant.Score = ant2.Score
-				ant2.Score = tmpScore
-
-				ant2.Path.Advance(1)
-				if ant2.Path.Len() == 0 {
-					ant2.Path = nil
+			rank := 2
+			if other := m.MyLiveAntAt(to); other != nil {
+				if other.Path == nil || other.Path.Len() == 0 {
+					continue
 				}
-				q = append(q, ant)
-				q = append(q, ant2)
-				continue
+				rank = 1
 			}
-			dir2 := ant2.Path.Dir(0)
-			if !ant2.HasLoc(m.Turn()) {
-				panic("ant2 does not have current loc!")
+			if rank > bestRank {
+				best = dir
+				bestRank = rank
 			}
-			to2 := m.T.NewLoc(ant2.Loc(m.Turn()), dir2)
-			if to2 == ant.Loc(m.Turn()) {
-				//				fmt.Fprintf(os.Stderr, "*-><-* case. Success!\n")
-				if ant.Path == nil {
-					panic("ant.Path == nil!")
-				}
-				tmpPath := ant.Path
-				ant.Path = ant2.Path
-				ant2.Path = tmpPath
-				if ant2.Path == nil {
-					panic(fmt.Sprintf("1. ant2.Path == nil! tmpPath: %v", tmpPath))
-				}
-				tmpTarget := ant.Target
-				ant.Target = ant2.Target
-				ant2.Target = tmpTarget
-				tmpScore := ant.Score
-				ant.Score = ant2.Score
-				ant2.Score = tmpScore
-
-				ant.Path.Advance(1)
-				if ant.Path.Len() == 0 {
-					ant.Path = nil
-				}
-				if ant2.Path == nil {
-					panic(fmt.Sprintf("2. ant2.Path == nil! tmpPath: %v", tmpPath))
-				}
-				ant2.Path.Advance(1)
-				if ant2.Path.Len() == 0 {
-					ant2.Path = nil
+		}
+		if best == 0 {
+			fmt.Fprintf(os.Stderr, "Can't move the ant off my hill at %d\n", hill.Loc)
+			continue
+		}
+		ant.Path = NewPath(m.T, hill.Loc)
+		ant.Path.Append(best)
+		ant.Target = m.T.NewLoc(hill.Loc, best)
+		ant.Score = MoveFromMyHillScore
+	}
+}
+
+func (m *Map) Conn(loc Location) (res []Location) {
+	return m.LandNeighbours(loc)
+}
+
+// swapPlans exchanges paths, targets and scores of two ants.
+func swapPlans(a, b *MyAnt) {
+	a.Path, b.Path = b.Path, a.Path
+	a.Target, b.Target = b.Target, a.Target
+	a.Score, b.Score = b.Score, a.Score
+}
+
+// advancePath skips the first step of the path, the ant is already there.
+func advancePath(ant *MyAnt) {
+	ant.Path.Advance(1)
+	if ant.Path.Len() == 0 {
+		ant.Path = nil
+	}
+}
+
+// ResolveConflicts decides which of my ants move on this turn, so no two ants
+// end up in one square. It returns the direction of every live ant, 0 if it stays.
+// An ant stepping onto an idle ant hands its path over to it, unless the idle ant
+// is held, and two ants stepping onto each other exchange their paths.
+// If several ants want one square, the one with the highest Score moves,
+// and the rest wait; the ties are broken by the order of ants.
+// A chain of ants moves if its head steps onto a free square,
+// and a cycle of three or more ants rotates.
+// When the deadline comes, paths are not exchanged anymore.
+func (m *Map) ResolveConflicts(deadline *Deadline) []Direction {
+	turn := m.Turn()
+	n := len(m.MyLiveAnts)
+	ind := make(map[*MyAnt]int)
+	for i, ant := range m.MyLiveAnts {
+		ind[ant] = i
+	}
+	dest := make([]Location, n)
+	setDest := func(i int) {
+		ant := m.MyLiveAnts[i]
+		loc := ant.Loc(turn)
+		dest[i] = loc
+		if ant.Path != nil && ant.Path.Len() == 0 {
+			ant.Path = nil
+		}
+		if ant.Path == nil {
+			return
+		}
+		if to := m.T.NewLoc(loc, ant.Path.Dir(0)); m.canStep(to) {
+			dest[i] = to
+		}
+	}
+	for i := range m.MyLiveAnts {
+		setDest(i)
+	}
+	// other returns the index of my ant standing at the destination of the ant, or -1
+	other := func(i int) int {
+		if dest[i] == m.MyLiveAnts[i].Loc(turn) {
+			return -1
+		}
+		if ant2 := m.MyLiveAntAt(dest[i]); ant2 != nil {
+			return ind[ant2]
+		}
+		return -1
+	}
+
+	// Every exchange makes paths shorter, so it ends
+	for changed := true; changed && deadline.Has(0); {
+		changed = false
+		for i, ant := range m.MyLiveAnts {
+			j := other(i)
+			if j < 0 {
+				continue
+			}
+			ant2 := m.MyLiveAnts[j]
+			switch {
+			case ant2.Path == nil:
+				if ant2.Hold || m.HasMyHillAt(ant.Loc(turn)) {
+					// The ant must stay, or the ant would stay on my hill
+					continue
 				}
-				q = append(q, ant)
-				q = append(q, ant2)
+				swapPlans(ant, ant2)
+				advancePath(ant2)
+			case dest[j] == ant.Loc(turn):
+				swapPlans(ant, ant2)
+				advancePath(ant)
+				advancePath(ant2)
+			default:
 				continue
 			}
-			//			fmt.Fprintf(os.Stderr, "Unknown case, ant2 at %v, dir2: %c \n", ant2.Loc(m.Turn()), dir2)
+			setDest(i)
+			setDest(j)
+			changed = true
+		}
+	}
+
+	// One ant per square
+	winner := make(map[Location]int)
+	for i, ant := range m.MyLiveAnts {
+		if dest[i] == ant.Loc(turn) {
+			continue
+		}
+		w, ok := winner[dest[i]]
+		if !ok || ant.Score > m.MyLiveAnts[w].Score {
+			winner[dest[i]] = i
+		}
+	}
+	for i, ant := range m.MyLiveAnts {
+		if dest[i] != ant.Loc(turn) && winner[dest[i]] != i {
+			dest[i] = ant.Loc(turn)
+		}
+	}
+
+	// Follow the chains
+	const (
+		unknown = iota
+		visiting
+		moves
+		stays
+	)
+	state := make([]int, n)
+	for i, ant := range m.MyLiveAnts {
+		if dest[i] == ant.Loc(turn) {
+			state[i] = stays
+		}
+	}
+	var stack []int
+	for i := range m.MyLiveAnts {
+		stack = stack[:0]
+		k := i
+		for k >= 0 && state[k] == unknown {
+			state[k] = visiting
+			stack = append(stack, k)
+			k = other(k)
+		}
+		result := moves
+		switch {
+		case k < 0:
+			// The head steps onto a free square
+		case state[k] == visiting:
+			// A cycle: the ants in it rotate, the ants leading into it can't exist,
+			// because the squares of the cycle are wanted by the cycle ants only
+			for len(stack) > 0 && stack[0] != k {
+				state[stack[0]] = stays
+				stack = stack[1:]
+			}
+		default:
+			result = state[k]
+		}
+		for _, j := range stack {
+			state[j] = result
+		}
+	}
+
+	dirs := make([]Direction, n)
+	for i, ant := range m.MyLiveAnts {
+		if state[i] == moves {
+			dirs[i] = m.T.GuessDir(ant.Loc(turn), dest[i])
 		}
 	}
+	return dirs
 }
 
 func (m *Map) HasMyHillAt(loc Location) bool {
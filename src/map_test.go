@@ -0,0 +1,351 @@
+package main
+
+import (
+	"rand"
+	"testing"
+)
+
+var mapTestTorus = Torus{10, 10}
+
+func newMapTestPath(from Location, dirs ...Direction) Path {
+	p := NewPath(mapTestTorus, from)
+	for _, dir := range dirs {
+		p.Append(dir)
+	}
+	return p
+}
+
+func mapTestInput(items ...Input) []Input {
+	return append([]Input{{What: Hill, Row: 1, Col: 1, Owner: Me}}, items...)
+}
+
+func TestUpdateLiveAnts(t *testing.T) {
+	tt := mapTestTorus
+	m := NewMap(tt, 4)
+	m.Update(mapTestInput(Input{What: Ant, Row: 1, Col: 1, Owner: Me}))
+	if len(m.MyLiveAnts) != 1 {
+		t.Fatalf("Turn 1: %d live ants, want: 1", len(m.MyLiveAnts))
+	}
+	ant := m.MyLiveAnts[0]
+	ant.Path = newMapTestPath(tt.Loc(1, 1), East, East)
+	ant.Target = tt.Loc(1, 3)
+	m.MoveAnts(nil)
+
+	// The move is confirmed
+	m.Update(mapTestInput(Input{What: Ant, Row: 1, Col: 2, Owner: Me}))
+	if len(m.MyLiveAnts) != 1 || m.MyLiveAnts[0] != ant {
+		t.Fatalf("Turn 2: the ant is lost, live ants: %v", m.MyLiveAnts)
+	}
+	if ant.Loc(2) != tt.Loc(1, 2) || ant.Path.Len() != 1 {
+		t.Errorf("Turn 2: loc: %d, path len: %d, want: %d, 1", ant.Loc(2), ant.Path.Len(), tt.Loc(1, 2))
+	}
+	m.MoveAnts(nil)
+
+	// The move has failed
+	m.Update(mapTestInput(Input{What: Ant, Row: 1, Col: 2, Owner: Me}))
+	if len(m.MyLiveAnts) != 1 || m.MyLiveAnts[0] != ant || len(m.MyAnts) != 1 {
+		t.Fatalf("Turn 3: the ant is lost, live ants: %v, all ants: %v", m.MyLiveAnts, m.MyAnts)
+	}
+	if ant.Loc(3) != tt.Loc(1, 2) || ant.Path.Len() != 1 || ant.Path.Dir(0) != East {
+		t.Errorf("Turn 3: loc: %d, path len: %d, want: %d, 1", ant.Loc(3), ant.Path.Len(), tt.Loc(1, 2))
+	}
+	if m.MyLiveAntAt(tt.Loc(1, 2)) != ant {
+		t.Errorf("Turn 3: MyLiveAntAt(%d) != ant", tt.Loc(1, 2))
+	}
+}
+
+func TestUpdateLiveAntsDead(t *testing.T) {
+	tt := mapTestTorus
+	m := NewMap(tt, 4)
+	m.Update(mapTestInput(Input{What: Ant, Row: 1, Col: 1, Owner: Me}))
+	ant := m.MyLiveAnts[0]
+	ant.Path = newMapTestPath(tt.Loc(1, 1), East)
+	m.MoveAnts(nil)
+
+	// The ant has died right after leaving the hill, and the next one is born.
+	// There's also an ant we knew nothing about.
+	m.Update(mapTestInput(
+		Input{What: DeadAnt, Row: 1, Col: 2, Owner: Me},
+		Input{What: Ant, Row: 1, Col: 1, Owner: Me},
+		Input{What: Ant, Row: 5, Col: 5, Owner: Me},
+	))
+	if ant.Alive || ant.DiedAt != 2 {
+		t.Errorf("The ant is alive: %v, died at: %d, want: false, 2", ant.Alive, ant.DiedAt)
+	}
+	if len(m.MyLiveAnts) != 2 || len(m.MyAnts) != 3 {
+		t.Fatalf("Live ants: %v, all ants: %v", m.MyLiveAnts, m.MyAnts)
+	}
+	for _, loc := range []Location{tt.Loc(1, 1), tt.Loc(5, 5)} {
+		if a := m.MyLiveAntAt(loc); a == nil || a.BornAt != 2 {
+			t.Errorf("No newborn ant at %d", loc)
+		}
+	}
+}
+
+func TestUpdateVisibility(t *testing.T) {
+	tt := Torus{12, 14}
+	m := NewMap(tt, 8)
+	rnd := rand.New(rand.NewSource(1))
+	input := []Input{
+		{What: Ant, Row: 1, Col: 1, Owner: Me},
+		{What: Ant, Row: 5, Col: 9, Owner: Me},
+		{What: Ant, Row: 11, Col: 3, Owner: Me},
+	}
+	lastSeen := make([]int, tt.Size())
+	for turn := 1; turn <= 30; turn++ {
+		if turn == 20 {
+			// One ant dies, another one jumps
+			input = []Input{input[0], {What: Ant, Row: 7, Col: 7, Owner: Me}}
+		}
+		m.Update(input)
+		for loc := 0; loc < tt.Size(); loc++ {
+			want := false
+			for _, ant := range m.MyLiveAnts {
+				if tt.Dist2(ant.Loc(turn), Location(loc)) <= 8 {
+					want = true
+				}
+			}
+			if want {
+				lastSeen[loc] = turn
+			}
+			if got := m.Visible(Location(loc)); got != want {
+				t.Fatalf("Turn %d: Visible(%d) = %v, want: %v", turn, loc, got, want)
+			}
+			if got := m.LastSeen(Location(loc)); got != lastSeen[loc] {
+				t.Fatalf("Turn %d: LastSeen(%d) = %d, want: %d", turn, loc, got, lastSeen[loc])
+			}
+		}
+
+		for _, ant := range m.MyLiveAnts {
+			ant.Path = NewPath(tt, ant.Loc(turn))
+			ant.Path.Append(Dirs[rnd.Intn(len(Dirs))])
+		}
+		m.MoveAnts(nil)
+		input = nil
+		for _, ant := range m.MyLiveAnts {
+			loc := ant.Loc(turn)
+			if ant.HasLoc(turn + 1) {
+				loc = ant.Loc(turn + 1)
+			}
+			input = append(input, Input{What: Ant, Row: tt.Row(loc), Col: tt.Col(loc), Owner: Me})
+		}
+	}
+}
+
+func TestClearHills(t *testing.T) {
+	tt := mapTestTorus
+	m := NewMap(tt, 4)
+	stay := true
+	m.StayOnHill = func(ant *MyAnt) bool {
+		return stay && ant.Loc(m.Turn()) == tt.Loc(1, 1)
+	}
+	// The ant on the hill is allowed to stay, the other one is blocked by water
+	m.Terrain[tt.Loc(5, 4)] = Water
+	input := []Input{
+		{What: Hill, Row: 1, Col: 1, Owner: Me},
+		{What: Ant, Row: 1, Col: 1, Owner: Me},
+		{What: Ant, Row: 1, Col: 2, Owner: Me},
+		{What: Hill, Row: 5, Col: 5, Owner: Me},
+		{What: Ant, Row: 5, Col: 5, Owner: Me},
+	}
+	m.Update(input)
+	blocked := m.MyLiveAntAt(tt.Loc(5, 5))
+	blocked.Path = newMapTestPath(tt.Loc(5, 5), West)
+	m.MoveAnts(nil)
+	if ant := m.MyLiveAntAt(tt.Loc(1, 1)); ant.HasLoc(2) {
+		t.Errorf("Turn 1: the ant allowed to stay has left the hill")
+	}
+	if !blocked.HasLoc(2) || blocked.Loc(2) == tt.Loc(5, 5) || blocked.Loc(2) == tt.Loc(5, 4) {
+		t.Errorf("Turn 1: the blocked ant has not left the hill")
+	}
+
+	// The hill has been blocked on the previous turn, so the ant leaves it anyway,
+	// but not to the square of the other ant
+	input[4] = Input{What: Ant, Row: tt.Row(blocked.Loc(2)), Col: tt.Col(blocked.Loc(2)), Owner: Me}
+	m.Update(input)
+	m.MoveAnts(nil)
+	ant := m.MyLiveAntAt(tt.Loc(1, 1))
+	if !ant.HasLoc(3) || ant.Loc(3) == tt.Loc(1, 1) || ant.Loc(3) == tt.Loc(1, 2) {
+		t.Errorf("Turn 2: the ant has not left the hill")
+	}
+
+	// Nobody is allowed to stay
+	stay = false
+	m.Update(input)
+	m.MoveAnts(nil)
+	if ant := m.MyLiveAntAt(tt.Loc(1, 1)); !ant.HasLoc(4) {
+		t.Errorf("Turn 3: the ant has not left the hill")
+	}
+}
+
+// TestClearHillsBlockedTwice checks that an ant which has lost its move off the hill
+// leaves it on the next turn, even if it has a path.
+func TestClearHillsBlockedTwice(t *testing.T) {
+	tt := mapTestTorus
+	m := NewMap(tt, 4)
+	m.Update([]Input{
+		{What: Hill, Row: 5, Col: 5, Owner: Me},
+		{What: Ant, Row: 5, Col: 5, Owner: Me},
+		{What: Ant, Row: 5, Col: 7, Owner: Me},
+	})
+	onHill := m.MyLiveAntAt(tt.Loc(5, 5))
+	onHill.Path = newMapTestPath(tt.Loc(5, 5), East)
+	onHill.Score = 1
+	other := m.MyLiveAntAt(tt.Loc(5, 7))
+	other.Path = newMapTestPath(tt.Loc(5, 7), West)
+	other.Score = 5
+	m.MoveAnts(nil)
+	if onHill.HasLoc(2) {
+		t.Fatalf("Turn 1: the ant on the hill has won the square")
+	}
+
+	m.Update([]Input{
+		{What: Hill, Row: 5, Col: 5, Owner: Me},
+		{What: Ant, Row: 5, Col: 5, Owner: Me},
+		{What: Ant, Row: 5, Col: 6, Owner: Me},
+	})
+	// The path goes into the idle ant, which doesn't take it from the hill
+	onHill.Path = newMapTestPath(tt.Loc(5, 5), East)
+	m.MoveAnts(nil)
+	if !onHill.HasLoc(3) || onHill.Loc(3) == tt.Loc(5, 5) {
+		t.Errorf("Turn 2: the hill is blocked again")
+	}
+}
+
+type resolveTestAnt struct {
+	Row, Col int
+	Score    int
+	Path     []Direction
+	Moves    Direction // the move on this turn, 0 if the ant stays
+}
+
+type resolveTest struct {
+	Name string
+	Ants []resolveTestAnt
+}
+
+var resolveTests = []resolveTest{
+	{
+		Name: "one square",
+		Ants: []resolveTestAnt{
+			{1, 1, 1, []Direction{East}, 0},
+			{1, 3, 5, []Direction{West}, West},
+			{0, 2, 1, []Direction{South}, 0},
+		},
+	},
+	{
+		Name: "chain",
+		Ants: []resolveTestAnt{
+			{1, 1, 1, []Direction{East}, East},
+			{1, 2, 1, []Direction{East}, East},
+			{1, 3, 1, []Direction{East}, East},
+		},
+	},
+	{
+		Name: "blocked chain",
+		Ants: []resolveTestAnt{
+			{1, 1, 1, []Direction{East}, 0},
+			{1, 2, 1, []Direction{East}, 0},
+			{1, 3, 1, []Direction{East}, 0},
+			{1, 4, 1, []Direction{North}, 0}, // water
+		},
+	},
+	{
+		Name: "cycle",
+		Ants: []resolveTestAnt{
+			{1, 1, 1, []Direction{East}, East},
+			{1, 2, 1, []Direction{South}, South},
+			{2, 2, 1, []Direction{West}, West},
+			{2, 1, 1, []Direction{North}, North},
+		},
+	},
+	{
+		Name: "chain into the loser",
+		Ants: []resolveTestAnt{
+			{1, 1, 1, []Direction{East}, 0},
+			{1, 2, 1, []Direction{East}, 0},
+			{1, 4, 2, []Direction{West}, West},
+		},
+	},
+	{
+		// The idle ant takes the path, so the first ant stays
+		Name: "idle",
+		Ants: []resolveTestAnt{
+			{1, 1, 1, []Direction{East, East}, 0},
+			{1, 2, 1, nil, East},
+		},
+	},
+	{
+		// The ants exchange their paths, and the second one continues the longer path
+		Name: "head-on",
+		Ants: []resolveTestAnt{
+			{1, 1, 1, []Direction{East, East}, 0},
+			{1, 2, 1, []Direction{West}, East},
+		},
+	},
+}
+
+func TestResolveConflicts(t *testing.T) {
+	tt := mapTestTorus
+	for _, test := range resolveTests {
+		m := NewMap(tt, 4)
+		m.Terrain[tt.Loc(0, 4)] = Water
+		input := []Input{{What: Hill, Row: 8, Col: 8, Owner: Me}}
+		for _, a := range test.Ants {
+			input = append(input, Input{What: Ant, Row: a.Row, Col: a.Col, Owner: Me})
+		}
+		m.Update(input)
+		var ants []*MyAnt
+		for _, a := range test.Ants {
+			ant := m.MyLiveAntAt(tt.Loc(a.Row, a.Col))
+			ant.Score = a.Score
+			if a.Path != nil {
+				ant.Path = newMapTestPath(tt.Loc(a.Row, a.Col), a.Path...)
+			}
+			ants = append(ants, ant)
+		}
+		m.MoveAnts(nil)
+		occupied := make(map[Location]bool)
+		for i, a := range test.Ants {
+			ant := ants[i]
+			loc := tt.Loc(a.Row, a.Col)
+			if ant.HasLoc(2) {
+				loc = ant.Loc(2)
+			}
+			if occupied[loc] {
+				t.Errorf("%s: two ants at (%d, %d)", test.Name, tt.Row(loc), tt.Col(loc))
+			}
+			occupied[loc] = true
+			want := tt.Loc(a.Row, a.Col)
+			if a.Moves != 0 {
+				want = tt.NewLoc(want, a.Moves)
+			}
+			if loc != want {
+				t.Errorf("%s: ant #%d is at (%d, %d), want: (%d, %d)", test.Name, i, tt.Row(loc), tt.Col(loc), tt.Row(want), tt.Col(want))
+			}
+		}
+	}
+}
+
+func TestResolveConflictsHold(t *testing.T) {
+	tt := mapTestTorus
+	m := NewMap(tt, 4)
+	m.Update([]Input{
+		{What: Ant, Row: 1, Col: 1, Owner: Me},
+		{What: Ant, Row: 1, Col: 2, Owner: Me},
+	})
+	ant := m.MyLiveAntAt(tt.Loc(1, 1))
+	ant.Path = newMapTestPath(tt.Loc(1, 1), East, East)
+	held := m.MyLiveAntAt(tt.Loc(1, 2))
+	held.Hold = true
+	m.MoveAnts(nil)
+	if held.HasLoc(2) || held.Path != nil {
+		t.Errorf("The held ant has taken the path")
+	}
+	if ant.HasLoc(2) || ant.Path == nil {
+		t.Errorf("The ant has handed over its path to the held ant")
+	}
+	if held.Hold {
+		t.Errorf("The ant is still held after the turn")
+	}
+}
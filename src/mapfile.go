@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbols of the .map file format
+const (
+	MapLand    = '.'
+	MapWater   = '%'
+	MapFood    = '*'
+	MapUnknown = '?'
+)
+
+// ReadGameMap parses a map in the format of the official maps:
+//
+//	rows 2
+//	cols 4
+//	players 2
+//	m .%0.
+//	m .1..
+//
+// Hills are '0'-'9', ants on hills are 'A'-'J'. Ants ('a'-'j') and food ('*') are
+// read as land, because the engine spawns them by itself.
+func ReadGameMap(r io.Reader) (gm *GameMap, err os.Error) {
+	br := bufio.NewReader(r)
+	gm = new(GameMap)
+	row := 0
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != os.EOF {
+			return nil, err
+		}
+		eof := err == os.EOF
+		line = strings.TrimSpace(line)
+		if line != "" {
+			if err = gm.parseLine(line, row); err != nil {
+				return nil, err
+			}
+			if strings.HasPrefix(line, "m ") {
+				row++
+			}
+		}
+		if eof {
+			break
+		}
+	}
+	if gm.Terrain == nil || row != gm.T.Rows {
+		return nil, fmt.Errorf("map has %d rows, want: %d", row, gm.T.Rows)
+	}
+	if err := gm.checkHills(); err != nil {
+		return nil, err
+	}
+	return gm, nil
+}
+
+// checkHills returns an error if some player has no hills.
+func (gm *GameMap) checkHills() os.Error {
+	if gm.Players <= 0 || len(gm.Hills) != gm.Players {
+		return fmt.Errorf("%d players with hills, want: %d > 0", len(gm.Hills), gm.Players)
+	}
+	for player, hills := range gm.Hills {
+		if len(hills) == 0 {
+			return fmt.Errorf("player %d has no hills", player)
+		}
+	}
+	return nil
+}
+
+func (gm *GameMap) parseLine(line string, row int) os.Error {
+	words := strings.SplitN(line, " ", 2)
+	if len(words) != 2 {
+		return fmt.Errorf("Invalid map line: %s", line)
+	}
+	if words[0] == "m" {
+		return gm.parseRow(words[1], row)
+	}
+	if gm.Terrain != nil {
+		return fmt.Errorf("%s after the first map row", words[0])
+	}
+	param, err := strconv.Atoi(words[1])
+	if err != nil {
+		return fmt.Errorf("Invalid map line: %s", line)
+	}
+	switch words[0] {
+	case "rows":
+		gm.T.Rows = param
+	case "cols":
+		gm.T.Cols = param
+	case "players":
+		gm.Players = param
+		gm.Hills = make([][]Location, param)
+	}
+	return nil
+}
+
+func (gm *GameMap) parseRow(s string, row int) os.Error {
+	if gm.Terrain == nil {
+		if gm.T.Rows <= 0 || gm.T.Cols <= 0 || gm.Players <= 0 {
+			return fmt.Errorf("rows, cols and players must be set before the first map row")
+		}
+		gm.Terrain = make([]Terrain, gm.T.Size())
+	}
+	if row >= gm.T.Rows {
+		return fmt.Errorf("too many map rows")
+	}
+	if len(s) != gm.T.Cols {
+		return fmt.Errorf("map row %d has %d cols, want: %d", row, len(s), gm.T.Cols)
+	}
+	for col := 0; col < len(s); col++ {
+		loc := gm.T.Loc(row, col)
+		c := int(s[col])
+		switch {
+		case c == MapWater:
+			gm.Terrain[loc] = Water
+		case c == MapUnknown:
+			gm.Terrain[loc] = Unknown
+		case c == MapLand || c == MapFood || c == '!' || 'a' <= c && c <= 'j':
+			gm.Terrain[loc] = Land
+		case '0' <= c && c <= '9' || 'A' <= c && c <= 'J':
+			player := c - '0'
+			if c >= 'A' {
+				player = c - 'A'
+			}
+			if player >= gm.Players {
+				return fmt.Errorf("hill of player %d at (%d, %d), but there are only %d players", player, row, col, gm.Players)
+			}
+			gm.Terrain[loc] = Land
+			gm.Hills[player] = append(gm.Hills[player], loc)
+		default:
+			return fmt.Errorf("unknown symbol '%c' at (%d, %d)", c, row, col)
+		}
+	}
+	return nil
+}
+
+// WriteGameMap writes the map in the format understood by ReadGameMap.
+// Unknown cells are written as '?'. Maps without hills of some player are written too,
+// so they can be looked at, but ReadGameMap rejects them as game maps.
+func WriteGameMap(w io.Writer, gm *GameMap) os.Error {
+	buf := make([]byte, gm.T.Size())
+	for loc, terrain := range gm.Terrain {
+		switch terrain {
+		case Water:
+			buf[loc] = MapWater
+		case Land:
+			buf[loc] = MapLand
+		default:
+			buf[loc] = MapUnknown
+		}
+	}
+	for player, hills := range gm.Hills {
+		for _, loc := range hills {
+			buf[loc] = byte('0' + player)
+		}
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "rows %d\ncols %d\nplayers %d\n", gm.T.Rows, gm.T.Cols, gm.Players)
+	for row := 0; row < gm.T.Rows; row++ {
+		fmt.Fprintf(bw, "m %s\n", buf[row*gm.T.Cols:(row+1)*gm.T.Cols])
+	}
+	return bw.Flush()
+}
+
+// NewGameMapFromMap returns what the bot knows about the world:
+// the discovered terrain and all hills seen on the current turn.
+// Players are the owners of these hills, renumbered in the order of owners,
+// so players without seen hills are skipped.
+func NewGameMapFromMap(m *Map) *GameMap {
+	gm := &GameMap{
+		T:       m.T,
+		Terrain: make([]Terrain, m.T.Size()),
+	}
+	copy(gm.Terrain, m.Terrain)
+	var hills []*Item
+	hills = append(hills, m.MyHills()...)
+	hills = append(hills, m.EnemyHills()...)
+	var owners []int
+	player := make(map[int]int)
+	for _, hill := range hills {
+		if _, ok := player[hill.Owner]; !ok {
+			player[hill.Owner] = -1
+			owners = append(owners, hill.Owner)
+		}
+	}
+	sort.Ints(owners)
+	for i, owner := range owners {
+		player[owner] = i
+	}
+	gm.Players = len(owners)
+	gm.Hills = make([][]Location, gm.Players)
+	for _, hill := range hills {
+		p := player[hill.Owner]
+		gm.Hills[p] = append(gm.Hills[p], hill.Loc)
+	}
+	return gm
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type mapFileTest struct {
+	Text    string
+	Err     bool
+	Players int
+	Hills   [][]Location
+	Water   []Location
+	Out     string // expected output of WriteGameMap, if differs from Text
+}
+
+var mapFileTests = []mapFileTest{
+	{
+		Text: `rows 2
+cols 4
+players 2
+m .%0.
+m .1..
+`,
+		Players: 2,
+		Hills:   [][]Location{[]Location{2}, []Location{5}},
+		Water:   []Location{1},
+	},
+	{
+		Text: `rows 3
+cols 3
+players 1
+m a*?
+m %A!
+m ...
+`,
+		Players: 1,
+		Hills:   [][]Location{[]Location{4}},
+		Water:   []Location{3},
+		Out: `rows 3
+cols 3
+players 1
+m ..?
+m %0.
+m ...
+`,
+	},
+	{
+		Text: "rows 1\ncols 2\nplayers 1\nm ..\n",
+		Err:  true, // no hills
+	},
+	{
+		Text: "rows 2\ncols 2\nplayers 1\nm .0\n",
+		Err:  true, // too few rows
+	},
+	{
+		Text: "rows 1\ncols 2\nplayers 1\nm .1\n",
+		Err:  true, // unknown player
+	},
+	{
+		Text: "rows 1\ncols 2\nplayers 1\nm .0.\n",
+		Err:  true, // too many cols
+	},
+}
+
+func TestGameMapFile(t *testing.T) {
+	for testInd, test := range mapFileTests {
+		gm, err := ReadGameMap(strings.NewReader(test.Text))
+		if test.Err {
+			if err == nil {
+				t.Errorf("test #%d: error expected", testInd)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test #%d: ReadGameMap: %v", testInd, err)
+			continue
+		}
+		if gm.Players != test.Players {
+			t.Errorf("test #%d: players: %d, want: %d", testInd, gm.Players, test.Players)
+		}
+		for player, hills := range test.Hills {
+			if len(gm.Hills[player]) != len(hills) {
+				t.Errorf("test #%d: player %d hills: %v, want: %v", testInd, player, gm.Hills[player], hills)
+				continue
+			}
+			for i, hill := range hills {
+				if gm.Hills[player][i] != hill {
+					t.Errorf("test #%d: player %d hills: %v, want: %v", testInd, player, gm.Hills[player], hills)
+				}
+			}
+		}
+		for _, loc := range test.Water {
+			if gm.Terrain[loc] != Water {
+				t.Errorf("test #%d: no water at %d", testInd, loc)
+			}
+		}
+		var buf bytes.Buffer
+		if err = WriteGameMap(&buf, gm); err != nil {
+			t.Errorf("test #%d: WriteGameMap: %v", testInd, err)
+			continue
+		}
+		want := test.Out
+		if want == "" {
+			want = test.Text
+		}
+		if buf.String() != want {
+			t.Errorf("test #%d: WriteGameMap:\n%s\nwant:\n%s", testInd, buf.String(), want)
+		}
+	}
+}
+
+func TestGameMapFromMapRoundTrip(t *testing.T) {
+	tt := mapTestTorus
+	m := NewMap(tt, 4)
+	// My hill is razed, the enemy is player 2, and the rest of the map is fog
+	m.Update([]Input{
+		{What: Ant, Row: 1, Col: 1, Owner: Me},
+		{What: Water, Row: 0, Col: 1},
+		{What: Hill, Row: 2, Col: 2, Owner: 2},
+	})
+	gm := NewGameMapFromMap(m)
+	var buf bytes.Buffer
+	if err := WriteGameMap(&buf, gm); err != nil {
+		t.Fatalf("WriteGameMap: %v", err)
+	}
+	got, err := ReadGameMap(&buf)
+	if err != nil {
+		t.Fatalf("ReadGameMap: %v", err)
+	}
+	if got.Players != 1 || len(got.Hills[0]) != 1 || got.Hills[0][0] != tt.Loc(2, 2) {
+		t.Errorf("players: %d, hills: %v, want: 1 player with the hill at %d", got.Players, got.Hills, tt.Loc(2, 2))
+	}
+	for loc := range gm.Terrain {
+		if got.Terrain[loc] != gm.Terrain[loc] {
+			t.Errorf("terrain at %d: %d, want: %d", loc, got.Terrain[loc], gm.Terrain[loc])
+		}
+	}
+
+	// No hills are seen, the map is written, but it's not a game map
+	m.Update([]Input{{What: Ant, Row: 1, Col: 1, Owner: Me}})
+	buf.Reset()
+	if err := WriteGameMap(&buf, NewGameMapFromMap(m)); err != nil {
+		t.Fatalf("WriteGameMap of a map without hills: %v", err)
+	}
+	if _, err := ReadGameMap(&buf); err == nil {
+		t.Errorf("ReadGameMap of a map without hills has not failed")
+	}
+}
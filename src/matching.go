@@ -0,0 +1,179 @@
+package main
+
+import (
+	"sort"
+)
+
+// Workers are not sent to targets farther than this
+const MaxMatchDist = 30
+
+// Only this many targets with the best scores are matched,
+// the matching takes O(targets^2 * (workers + targets))
+const MaxMatchTargets = 100
+
+type matchingPlanner struct {
+	size      int
+	targetInd LocIntMap // index+1 in targets
+}
+
+// NewMatchingPlanner returns a planner which maximizes the total score
+// minus the total distance of all assignments at once.
+// Previous assignments are kept unless their reassignment is better
+// by ReassignThresholdRatio, like the greedy planner does.
+// Previous assignments to the targets which are gone are dropped.
+func NewMatchingPlanner(size int) Planner {
+	return &matchingPlanner{
+		size:      size,
+		targetInd: NewLocIntMap(size),
+	}
+}
+
+func (p *matchingPlanner) Plan(l Locator, prev []Assignment, workerSet LocatedSet, targets []Location, scores []int) (res []Assignment) {
+	workers := workerSet.All()
+
+	// Merge duplicate targets
+	p.targetInd.Clear()
+	var allTargets []Location
+	var allScores []int
+	for i, t := range targets {
+		if ind := p.targetInd.Get(t); ind > 0 {
+			if allScores[ind-1] < scores[i] {
+				allScores[ind-1] = scores[i]
+			}
+			continue
+		}
+		allTargets = append(allTargets, t)
+		allScores = append(allScores, scores[i])
+		p.targetInd.Add(t, len(allTargets))
+	}
+	if len(allTargets) > MaxMatchTargets {
+		sort.Sort(&sorter{allTargets, allScores})
+		allTargets = allTargets[:MaxMatchTargets]
+		allScores = allScores[:MaxMatchTargets]
+		p.targetInd.Clear()
+		for i, t := range allTargets {
+			p.targetInd.Add(t, i+1)
+		}
+	}
+	if len(allTargets) == 0 || len(workers) == 0 {
+		return nil
+	}
+
+	// value is the score of the previous assignment or of the target
+	value := make([][]int64, len(allTargets))
+	for i := range value {
+		value[i] = make([]int64, len(workers))
+		for j := range workers {
+			value[i][j] = int64(allScores[i])
+		}
+	}
+	workerInd := make(map[Location]int)
+	for j, w := range workers {
+		workerInd[w] = j
+	}
+	for _, assign := range prev {
+		j, ok := workerInd[assign.Worker]
+		i := p.targetInd.Get(assign.Target) - 1
+		if !ok || i < 0 {
+			continue
+		}
+		value[i][j] = int64(float64(assign.Score) * ReassignThresholdRatio)
+	}
+
+	// Targets are rows, workers and a dummy column per target are columns.
+	// Matching with a dummy column or with a non-profitable pair means no assignment.
+	cost := make([][]int64, len(allTargets))
+	for i, t := range allTargets {
+		cost[i] = make([]int64, len(workers)+len(allTargets))
+		for j, w := range workers {
+			dist := l.Dist(w, t)
+			if dist < 0 || dist == NoPath || dist > MaxMatchDist {
+				continue
+			}
+			if c := int64(dist) - value[i][j]; c < 0 {
+				cost[i][j] = c
+			}
+		}
+	}
+	for i, j := range MinCostMatching(cost) {
+		if j < len(workers) && cost[i][j] < 0 {
+			res = append(res, Assignment{
+				Worker: workers[j],
+				Target: allTargets[i],
+				Score:  allScores[i],
+			})
+		}
+	}
+	return
+}
+
+// MinCostMatching solves the assignment problem with the Hungarian algorithm.
+// The number of rows must not exceed the number of columns.
+// It returns the column matched with each row.
+func MinCostMatching(cost [][]int64) []int {
+	const inf = int64(1) << 62
+	n := len(cost)
+	if n == 0 {
+		return nil
+	}
+	m := len(cost[0])
+	// Rows and columns are numbered from 1, 0 is a fake column
+	u := make([]int64, n+1)
+	v := make([]int64, m+1)
+	p := make([]int, m+1) // row matched with the column
+	way := make([]int, m+1)
+	minv := make([]int64, m+1)
+	used := make([]bool, m+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		for j := range minv {
+			minv[j] = inf
+			used[j] = false
+		}
+		for {
+			used[j0] = true
+			i0 := p[j0]
+			delta := inf
+			j1 := 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				if cur := cost[i0-1][j-1] - u[i0] - v[j]; cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		// Flip the augmenting path
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+	res := make([]int, n)
+	for j := 1; j <= m; j++ {
+		if p[j] != 0 {
+			res[p[j]-1] = j - 1
+		}
+	}
+	return res
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"rand"
+	"testing"
+)
+
+// lineLocator measures distances along a line.
+type lineLocator struct{}
+
+func (lineLocator) Dist(from, to Location) int {
+	if from > to {
+		return int(from - to)
+	}
+	return int(to - from)
+}
+
+type lineWorkers []Location
+
+func (s lineWorkers) All() []Location {
+	res := make([]Location, len(s))
+	copy(res, s)
+	return res
+}
+
+func (s lineWorkers) FindNear(loc Location, score int, ok func(Location, int, bool) bool) (Location, bool) {
+	panic("FindNear is not used by the matching planner")
+}
+
+// bruteForceMatching returns the minimal cost of matching all rows.
+func bruteForceMatching(cost [][]int64, row int, used []bool) int64 {
+	if row == len(cost) {
+		return 0
+	}
+	var best int64
+	found := false
+	for j := range cost[row] {
+		if used[j] {
+			continue
+		}
+		used[j] = true
+		if c := cost[row][j] + bruteForceMatching(cost, row+1, used); !found || c < best {
+			best = c
+			found = true
+		}
+		used[j] = false
+	}
+	return best
+}
+
+func TestMinCostMatching(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for test := 0; test < 100; test++ {
+		n := 1 + rnd.Intn(5)
+		m := n + rnd.Intn(3)
+		cost := make([][]int64, n)
+		for i := range cost {
+			cost[i] = make([]int64, m)
+			for j := range cost[i] {
+				cost[i][j] = int64(rnd.Intn(20) - 10)
+			}
+		}
+		match := MinCostMatching(cost)
+		var got int64
+		used := make(map[int]bool)
+		for i, j := range match {
+			if used[j] {
+				t.Fatalf("test #%d: column %d is matched twice, cost: %v", test, j, cost)
+			}
+			used[j] = true
+			got += cost[i][j]
+		}
+		if want := bruteForceMatching(cost, 0, make([]bool, m)); got != want {
+			t.Errorf("test #%d: cost: %d, want: %d, matrix: %v", test, got, want, cost)
+		}
+	}
+}
+
+type matchingPlannerTest struct {
+	Workers []Location
+	Prev    []Assignment
+	Targets []Location
+	Scores  []int
+	Want    map[Location]Location // worker -> target
+}
+
+var matchingPlannerTests = []matchingPlannerTest{
+	{
+		// The closest worker of the first target is needed for the second one
+		Workers: []Location{0, 10},
+		Targets: []Location{9, 19},
+		Scores:  []int{100, 100},
+		Want:    map[Location]Location{0: 9, 10: 19},
+	},
+	{
+		// The total distance is minimized
+		Workers: []Location{0, 4},
+		Targets: []Location{5, 3},
+		Scores:  []int{50, 1000},
+		Want:    map[Location]Location{0: 3, 4: 5},
+	},
+	{
+		// Extra workers are idle
+		Workers: []Location{0, 1, 2},
+		Targets: []Location{5},
+		Scores:  []int{100},
+		Want:    map[Location]Location{2: 5},
+	},
+	{
+		// Too far
+		Workers: []Location{0},
+		Targets: []Location{MaxMatchDist + 1},
+		Scores:  []int{100},
+		Want:    map[Location]Location{},
+	},
+	{
+		// Swapping targets would be shorter, but the previous assignment is kept
+		Workers: []Location{0, 10},
+		Prev:    []Assignment{{Worker: 0, Target: 5, Score: 100}},
+		Targets: []Location{4, 5},
+		Scores:  []int{100, 100},
+		Want:    map[Location]Location{0: 5, 10: 4},
+	},
+	{
+		// A much better target wins over the previous assignment
+		Workers: []Location{0},
+		Prev:    []Assignment{{Worker: 0, Target: 5, Score: 100}},
+		Targets: []Location{5, 6},
+		Scores:  []int{100, 1000},
+		Want:    map[Location]Location{0: 6},
+	},
+	{
+		// The previous target is gone
+		Workers: []Location{0},
+		Prev:    []Assignment{{Worker: 0, Target: 1, Score: 1000}},
+		Targets: []Location{20},
+		Scores:  []int{100},
+		Want:    map[Location]Location{0: 20},
+	},
+}
+
+func TestMatchingPlanner(t *testing.T) {
+	p := NewMatchingPlanner(100)
+	for i, test := range matchingPlannerTests {
+		plan := p.Plan(lineLocator{}, test.Prev, lineWorkers(test.Workers), test.Targets, test.Scores)
+		if len(plan) != len(test.Want) {
+			t.Errorf("test #%d: plan: %v, want: %v", i, plan, test.Want)
+			continue
+		}
+		for _, assign := range plan {
+			if target, ok := test.Want[assign.Worker]; !ok || target != assign.Target {
+				t.Errorf("test #%d: plan: %v, want: %v", i, plan, test.Want)
+				break
+			}
+		}
+	}
+}
+
+func TestMatchingPlannerMaxTargets(t *testing.T) {
+	// The nearest targets have the lowest scores and are not matched
+	var targets []Location
+	var scores []int
+	for i := 0; i < MaxMatchTargets+10; i++ {
+		targets = append(targets, Location(i+1))
+		scores = append(scores, 100+i)
+	}
+	p := NewMatchingPlanner(MaxMatchTargets + 20)
+	plan := p.Plan(lineLocator{}, nil, lineWorkers([]Location{0}), targets, scores)
+	if len(plan) != 1 || plan[0].Target <= 10 {
+		t.Errorf("plan: %v, want a target farther than 10", plan)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+// Enemy ants move, so their sightings are forgotten after this number of turns
+const MaxEnemySightingAge = 5
+
+// MemItem is an item seen on some turn, which can be out of view now.
+type MemItem struct {
+	Item
+	SeenAt int  // the last turn the item has been seen
+	Razed  bool // for hills: the square has been seen without the hill
+}
+
+// Memory remembers food, hills and enemy ants after they leave the view.
+// An item is forgotten when its square is in view again, but the item is not there.
+// Razed hills are kept, but marked.
+type Memory struct {
+	m   *Map
+	At  [][]*MemItem
+	All []*MemItem
+}
+
+func NewMemory(m *Map) *Memory {
+	return &Memory{
+		m:  m,
+		At: make([][]*MemItem, m.T.Size()),
+	}
+}
+
+func (mem *Memory) find(item *Item) *MemItem {
+	for _, known := range mem.At[item.Loc] {
+		if known.What == item.What && known.Owner == item.Owner {
+			return known
+		}
+	}
+	return nil
+}
+
+func (mem *Memory) remove(known *MemItem) {
+	at := mem.At[known.Loc]
+	for i := range at {
+		if at[i] == known {
+			at[i] = at[len(at)-1]
+			mem.At[known.Loc] = at[:len(at)-1]
+			return
+		}
+	}
+}
+
+// Update must be called after the map has been updated with the current turn.
+func (mem *Memory) Update() {
+	turn := mem.m.Turn()
+	for _, item := range mem.m.Items[turn].All {
+		if item.What == DeadAnt || item.What == Ant && item.Owner == Me {
+			continue
+		}
+		if known := mem.find(item); known != nil {
+			known.SeenAt = turn
+			known.Razed = false
+			continue
+		}
+		known := &MemItem{Item: *item, SeenAt: turn}
+		mem.At[item.Loc] = append(mem.At[item.Loc], known)
+		mem.All = append(mem.All, known)
+	}
+
+	// Forget the items which are not there anymore
+	all := mem.All[:0]
+	for _, known := range mem.All {
+		if known.SeenAt == turn || known.Razed {
+			all = append(all, known)
+			continue
+		}
+		inView := mem.m.Visible(known.Loc)
+		if known.What == Hill && inView {
+			known.Razed = true
+			all = append(all, known)
+			continue
+		}
+		if inView || known.What == Ant && turn-known.SeenAt > MaxEnemySightingAge {
+			mem.remove(known)
+			continue
+		}
+		all = append(all, known)
+	}
+	mem.All = all
+}
+
+// Food returns locations of all known food.
+func (mem *Memory) Food() (res []Location) {
+	for _, known := range mem.All {
+		if known.What == Food {
+			res = append(res, known.Loc)
+		}
+	}
+	return
+}
+
+// EnemyHills returns enemy hills which are not known to be razed.
+func (mem *Memory) EnemyHills() (res []*MemItem) {
+	for _, known := range mem.All {
+		if known.What == Hill && known.Owner != Me && !known.Razed {
+			res = append(res, known)
+		}
+	}
+	return
+}
+
+// RazedHills returns all hills known to be razed, including mine.
+func (mem *Memory) RazedHills() (res []*MemItem) {
+	for _, known := range mem.All {
+		if known.What == Hill && known.Razed {
+			res = append(res, known)
+		}
+	}
+	return
+}
+
+// Enemy returns the last known locations of enemy ants.
+func (mem *Memory) Enemy() (res []*MemItem) {
+	for _, known := range mem.All {
+		if known.What == Ant && known.Owner != Me {
+			res = append(res, known)
+		}
+	}
+	return
+}
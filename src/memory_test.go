@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	tt := Torus{20, 20}
+	m := NewMap(tt, 4)
+	myAnt := func(row, col int) Input {
+		return Input{What: Ant, Row: row, Col: col, Owner: Me}
+	}
+
+	m.Update([]Input{
+		myAnt(1, 1),
+		{What: Hill, Row: 1, Col: 1, Owner: Me},
+		{What: Food, Row: 1, Col: 3},
+		{What: Hill, Row: 3, Col: 1, Owner: 1},
+		{What: Ant, Row: 1, Col: 0, Owner: 1},
+	})
+	// Everything is out of view now
+	m.Update([]Input{myAnt(10, 10)})
+	if food := m.Mem.Food(); len(food) != 1 || food[0] != tt.Loc(1, 3) {
+		t.Errorf("Turn 2: food: %v, want: [%d]", food, tt.Loc(1, 3))
+	}
+	if hills := m.Mem.EnemyHills(); len(hills) != 1 || hills[0].Loc != tt.Loc(3, 1) {
+		t.Errorf("Turn 2: enemy hills: %v", hills)
+	}
+	if enemy := m.Mem.Enemy(); len(enemy) != 1 || enemy[0].SeenAt != 1 {
+		t.Errorf("Turn 2: enemy: %v", enemy)
+	}
+
+	// The food and the enemy ant are not there anymore
+	m.Update([]Input{myAnt(1, 2), {What: Hill, Row: 1, Col: 1, Owner: Me}})
+	if food := m.Mem.Food(); len(food) != 0 {
+		t.Errorf("Turn 3: food: %v, want: []", food)
+	}
+	if enemy := m.Mem.Enemy(); len(enemy) != 0 {
+		t.Errorf("Turn 3: enemy: %v, want: []", enemy)
+	}
+	if hills := m.Mem.EnemyHills(); len(hills) != 1 {
+		t.Errorf("Turn 3: enemy hills: %v", hills)
+	}
+
+	// The hill has been razed
+	m.Update([]Input{myAnt(2, 1), {What: Hill, Row: 1, Col: 1, Owner: Me}})
+	if hills := m.Mem.EnemyHills(); len(hills) != 0 {
+		t.Errorf("Turn 4: enemy hills: %v, want: []", hills)
+	}
+	if hills := m.Mem.RazedHills(); len(hills) != 1 || hills[0].Loc != tt.Loc(3, 1) {
+		t.Errorf("Turn 4: razed hills: %v", hills)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var engineMode = flag.Bool("engine", false, "play a local game between the bots given as arguments")
+var engineRows = flag.Int("rows", 60, "number of rows of the generated map")
+var engineCols = flag.Int("cols", 60, "number of columns of the generated map")
+var engineTurns = flag.Int("turns", 500, "maximum number of turns")
+var engineSeed = flag.Int64("seed", 0, "game seed; the current time is used if 0")
+var engineMap = flag.String("map", "", "path to a .map file; a random map is generated if empty")
+
+// Play runs a local game. Each argument is either a command line of a bot
+// or "@random" for the built-in random bot. Without arguments,
+// the current binary plays against itself.
+func Play(args []string) os.Error {
+	if len(args) == 0 {
+		args = []string{os.Args[0], os.Args[0]}
+	}
+	seed := *engineSeed
+	if seed == 0 {
+		seed = time.Nanoseconds()
+	}
+	opts := GameOptions{
+		Params: Params{
+			LoadTime:      3000,
+			TurnTime:      500,
+			Turns:         *engineTurns,
+			ViewRadius2:   77,
+			AttackRadius2: 5,
+			SpawnRadius2:  1,
+			PlayerSeed:    seed,
+		},
+		FoodPercent: DefaultFoodPercent,
+		FoodStart:   DefaultFoodStart,
+	}
+	var gm *GameMap
+	if *engineMap != "" {
+		f, err := os.Open(*engineMap)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if gm, err = ReadGameMap(f); err != nil {
+			return fmt.Errorf("ReadGameMap(%s): %v", *engineMap, err)
+		}
+		if gm.Players != len(args) {
+			return fmt.Errorf("map %s is for %d players, but %d bots are given", *engineMap, gm.Players, len(args))
+		}
+	} else {
+		gm = GenerateGameMap(Torus{Rows: *engineRows, Cols: *engineCols}, len(args), 10, seed)
+	}
+
+	var players []Player
+	for _, arg := range args {
+		if arg == "@random" {
+			players = append(players, NewBotPlayer(new(RandomBot)))
+			continue
+		}
+		pl, err := NewProcPlayer(arg, opts.Params)
+		if err != nil {
+			return fmt.Errorf("NewProcPlayer(%s): %v", arg, err)
+		}
+		players = append(players, pl)
+	}
+
+	g := NewGame(gm, opts)
+	RunGame(g, players)
+	fmt.Fprintf(os.Stderr, "seed: %d, turns: %d, scores: %v\n", seed, g.Turn(), g.Scores())
+	return nil
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"exec"
+	"fmt"
+	"io"
+	"os"
+	"rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// procPlayer runs a bot as a separate process and talks to it via stdin/stdout.
+type procPlayer struct {
+	cmd        *exec.Cmd
+	in         io.WriteCloser
+	lines      chan string
+	loadTimeMs int
+	turnTimeMs int
+	started    bool
+	failed     bool
+}
+
+func NewProcPlayer(command string, p Params) (Player, os.Error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, os.NewError("empty bot command")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	pp := &procPlayer{
+		cmd:        cmd,
+		in:         in,
+		lines:      make(chan string, 100),
+		loadTimeMs: p.LoadTime,
+		turnTimeMs: p.TurnTime,
+	}
+	go pp.read(bufio.NewReader(out))
+	return pp, nil
+}
+
+func (pp *procPlayer) read(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			close(pp.lines)
+			return
+		}
+		pp.lines <- strings.TrimSpace(line)
+	}
+}
+
+func (pp *procPlayer) fail() {
+	pp.failed = true
+	pp.cmd.Process.Kill()
+}
+
+func (pp *procPlayer) Turn(lines []string) (orders []string, err os.Error) {
+	timeoutMs := pp.turnTimeMs
+	if !pp.started {
+		timeoutMs = pp.loadTimeMs
+		pp.started = true
+	}
+	if _, err = io.WriteString(pp.in, strings.Join(lines, "\n")+"\n"); err != nil {
+		pp.fail()
+		return nil, err
+	}
+	timeout := time.After(int64(timeoutMs) * 1000 * 1000)
+	for {
+		select {
+		case line, ok := <-pp.lines:
+			if !ok {
+				pp.fail()
+				return nil, os.NewError("bot has exited")
+			}
+			if line == "go" {
+				return orders, nil
+			}
+			if line != "" {
+				orders = append(orders, line)
+			}
+		case <-timeout:
+			pp.fail()
+			return nil, fmt.Errorf("timeout (%d ms)", timeoutMs)
+		}
+	}
+	panic("unreachable")
+}
+
+func (pp *procPlayer) End(lines []string) {
+	if pp.failed {
+		pp.cmd.Wait()
+		return
+	}
+	io.WriteString(pp.in, strings.Join(lines, "\n")+"\n")
+	pp.in.Close()
+	pp.cmd.Wait()
+}
+
+// botPlayer runs a Bot inside the engine process.
+// It passes the lines through the same parsers which are used by ReadParams and Loop.
+type botPlayer struct {
+	b       Bot
+	p       Params
+	started bool
+}
+
+func NewBotPlayer(b Bot) Player {
+	return &botPlayer{b: b}
+}
+
+func (bp *botPlayer) Turn(lines []string) (orders []string, err os.Error) {
+	if !bp.started {
+		bp.started = true
+		for _, line := range lines {
+			if line == "ready" {
+				break
+			}
+			if err = parseParam(&bp.p, line); err != nil {
+				return
+			}
+		}
+		return nil, bp.b.Init(bp.p)
+	}
+	var input []Input
+	turn := 0
+	for _, line := range lines {
+		if line == "go" {
+			break
+		}
+		if strings.HasPrefix(line, "turn ") {
+			turn, _ = strconv.Atoi(line[len("turn "):])
+			continue
+		}
+		in, err := parseInput(line)
+		if err != nil {
+			return nil, err
+		}
+		input = append(input, in)
+	}
+	var deadline *Deadline
+	if bp.p.TurnTime > 0 {
+		deadline = NewDeadline(bp.p.TurnTime - LagMs)
+	}
+	res, err := bp.b.DoTurn(turn, input, deadline)
+	if err != nil {
+		return nil, err
+	}
+	for _, order := range res {
+		orders = append(orders, formatOrder(order))
+	}
+	return
+}
+
+func (bp *botPlayer) End(lines []string) {
+	var res GameResult
+	for _, line := range lines {
+		if line == "end" || line == "go" {
+			continue
+		}
+		if err := res.parseLine(line); err != nil {
+			fmt.Fprintf(os.Stderr, "End of game: %v\n", err)
+		}
+	}
+	bp.b.End(&res)
+}
+
+// RandomBot is a scripted opponent for local games.
+// Every ant steps into a random passable direction.
+type RandomBot struct {
+	t     Torus
+	water []bool
+	rnd   *rand.Rand
+}
+
+func (b *RandomBot) Init(p Params) os.Error {
+	b.t = Torus{Rows: p.Rows, Cols: p.Cols}
+	b.water = make([]bool, b.t.Size())
+	b.rnd = rand.New(rand.NewSource(p.PlayerSeed))
+	return nil
+}
+
+func (b *RandomBot) DoTurn(turn int, input []Input, deadline *Deadline) (orders []Order, err os.Error) {
+	busy := make([]bool, b.t.Size())
+	var mine []Location
+	for _, in := range input {
+		loc := b.t.Loc(in.Row, in.Col)
+		switch in.What {
+		case Water:
+			b.water[loc] = true
+		case Food:
+			busy[loc] = true
+		case Ant:
+			busy[loc] = true
+			if in.Owner == Me {
+				mine = append(mine, loc)
+			}
+		}
+	}
+	for _, loc := range mine {
+		for _, ind := range b.rnd.Perm(len(Dirs)) {
+			newLoc := b.t.NewLoc(loc, Dirs[ind])
+			if b.water[newLoc] || busy[newLoc] {
+				continue
+			}
+			busy[newLoc] = true
+			orders = append(orders, Order{Row: b.t.Row(loc), Col: b.t.Col(loc), Dir: Dirs[ind]})
+			break
+		}
+	}
+	return
+}
+
+func (b *RandomBot) End(result *GameResult) {
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"json"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const ReplayRevision = 3
+
+type ReplayTurn struct {
+	Turn   int      `json:"turn"`
+	Input  []string `json:"input"`
+	Orders []string `json:"orders"`
+	TimeMs int      `json:"time_ms"`
+	Error  string   `json:"error"`
+}
+
+type ReplayMap struct {
+	Rows int      `json:"rows"`
+	Cols int      `json:"cols"`
+	Data []string `json:"data"`
+}
+
+// ReplayData has the fields of the challenge replay format
+// which can be restored from the bot's point of view.
+// Ants are [row, col, start turn, conversion turn, end turn, player, moves]
+// and food is [row, col, start turn, end turn], like in the challenge replays,
+// but only my ants are there: enemy ants can't be told apart between turns.
+// Food is recorded while it's seen, so food out of view ends early.
+// Raw protocol lines are kept in the extra bot_setup and bot_turns fields.
+type ReplayData struct {
+	Revision      int             `json:"revision"`
+	Players       int             `json:"players"`
+	LoadTime      int             `json:"loadtime"`
+	TurnTime      int             `json:"turntime"`
+	Turns         int             `json:"turns"`
+	ViewRadius2   int             `json:"viewradius2"`
+	AttackRadius2 int             `json:"attackradius2"`
+	SpawnRadius2  int             `json:"spawnradius2"`
+	PlayerSeed    int64           `json:"player_seed"`
+	Map           ReplayMap       `json:"map"`
+	Ants          [][]interface{} `json:"ants"`
+	Food          [][]int         `json:"food"`
+	BotSetup      []string        `json:"bot_setup"`
+	BotTurns      []ReplayTurn    `json:"bot_turns"`
+	BotEnd        []string        `json:"bot_end"`
+	Scores        []int           `json:"bot_scores"`
+}
+
+// Replay is a game recorded by the bot.
+type Replay struct {
+	Challenge    string     `json:"challenge"`
+	ReplayFormat string     `json:"replayformat"`
+	ReplayData   ReplayData `json:"replaydata"`
+}
+
+func NewReplay() *Replay {
+	return &Replay{
+		Challenge:    "ants",
+		ReplayFormat: "json",
+		ReplayData:   ReplayData{Revision: ReplayRevision},
+	}
+}
+
+// AddSetup records a raw line of the setup phase.
+func (r *Replay) AddSetup(line string) {
+	r.ReplayData.BotSetup = append(r.ReplayData.BotSetup, line)
+}
+
+// AddTurn records raw input lines of the turn, the orders sent, the time spent
+// and the error if the turn has failed.
+func (r *Replay) AddTurn(turn int, input []string, orders []string, timeMs int, err os.Error) {
+	t := ReplayTurn{
+		Turn:   turn,
+		Input:  input,
+		Orders: orders,
+		TimeMs: timeMs,
+	}
+	if err != nil {
+		t.Error = fmt.Sprintf("%v", err)
+	}
+	r.ReplayData.BotTurns = append(r.ReplayData.BotTurns, t)
+}
+
+// SetEnd records raw lines of the end-of-game block.
+func (r *Replay) SetEnd(lines []string) {
+	r.ReplayData.BotEnd = lines
+}
+
+// Params returns the game parameters restored from the setup lines.
+func (r *Replay) Params() (p Params) {
+	for _, line := range r.ReplayData.BotSetup {
+		if line == "ready" {
+			break
+		}
+		parseParam(&p, line)
+	}
+	return
+}
+
+// fill sets the fields of the challenge format from the recorded lines.
+// Cells with no known water are written as land.
+func (r *Replay) fill() {
+	d := &r.ReplayData
+	p := r.Params()
+	d.LoadTime = p.LoadTime
+	d.TurnTime = p.TurnTime
+	d.Turns = p.Turns
+	d.ViewRadius2 = p.ViewRadius2
+	d.AttackRadius2 = p.AttackRadius2
+	d.SpawnRadius2 = p.SpawnRadius2
+	d.PlayerSeed = p.PlayerSeed
+	d.Players = p.Players
+
+	t := Torus{Rows: p.Rows, Cols: p.Cols}
+	data := make([]byte, t.Size())
+	for i := range data {
+		data[i] = MapLand
+	}
+	if d.Players == 0 {
+		d.Players = 1
+	}
+	for _, turn := range d.BotTurns {
+		for _, line := range turn.Input {
+			in, err := parseInput(line)
+			if err != nil || in.Row < 0 || in.Row >= t.Rows || in.Col < 0 || in.Col >= t.Cols {
+				continue
+			}
+			if in.What == Water {
+				data[t.Loc(in.Row, in.Col)] = MapWater
+			}
+			if in.What == Ant || in.What == Hill || in.What == DeadAnt {
+				if in.Owner+1 > d.Players {
+					d.Players = in.Owner + 1
+				}
+			}
+		}
+	}
+	if len(d.BotEnd) > 0 {
+		var res GameResult
+		for _, line := range d.BotEnd {
+			res.parseLine(line)
+		}
+		d.Scores = res.Scores
+	}
+	d.Map = ReplayMap{Rows: t.Rows, Cols: t.Cols}
+	for row := 0; row < t.Rows; row++ {
+		d.Map.Data = append(d.Map.Data, string(data[row*t.Cols:(row+1)*t.Cols]))
+	}
+	r.fillAnts(t)
+}
+
+type replayAnt struct {
+	loc        Location // the current one
+	next       Location // where the order of the current turn leads
+	move       byte     // the order of the current turn, '-' if none
+	row, col   int
+	start, end int
+	moves      []byte
+}
+
+// fillAnts restores my ants from the input and the orders sent, and the food seen.
+// An ant which is not found where its order leads has failed to move, if there is
+// my ant at its previous location, or has died otherwise.
+func (r *Replay) fillAnts(t Torus) {
+	d := &r.ReplayData
+	var all, live []*replayAnt
+	foodStart := make(map[Location]int) // start turn+1 of the food seen now
+	var foodLocs []Location
+	d.Ants = nil
+	d.Food = nil
+	endTurn := 0
+	for _, turn := range d.BotTurns {
+		endTurn = turn.Turn + 1
+		var mine []Location
+		isMine := make(map[Location]bool)
+		seenFood := make(map[Location]bool)
+		for _, line := range turn.Input {
+			in, err := parseInput(line)
+			if err != nil || in.Row < 0 || in.Row >= t.Rows || in.Col < 0 || in.Col >= t.Cols {
+				continue
+			}
+			loc := t.Loc(in.Row, in.Col)
+			if in.What == Ant && in.Owner == Me && !isMine[loc] {
+				mine = append(mine, loc)
+				isMine[loc] = true
+			}
+			if in.What == Food {
+				seenFood[loc] = true
+				if foodStart[loc] == 0 {
+					foodStart[loc] = turn.Turn + 1
+					foodLocs = append(foodLocs, loc)
+				}
+			}
+		}
+
+		// Food which is not seen any more
+		var stillFood []Location
+		for _, loc := range foodLocs {
+			if seenFood[loc] {
+				stillFood = append(stillFood, loc)
+				continue
+			}
+			d.Food = append(d.Food, []int{t.Row(loc), t.Col(loc), foodStart[loc] - 1, turn.Turn})
+			foodStart[loc] = 0
+		}
+		foodLocs = stillFood
+
+		// Match my ants with the input
+		claimed := make(map[Location]bool)
+		var stillLive []*replayAnt
+		for _, ant := range live {
+			switch {
+			case isMine[ant.next] && !claimed[ant.next]:
+				ant.loc = ant.next
+			case isMine[ant.loc] && !claimed[ant.loc]:
+				ant.moves[len(ant.moves)-1] = '-'
+			default:
+				ant.end = turn.Turn
+				continue
+			}
+			claimed[ant.loc] = true
+			stillLive = append(stillLive, ant)
+		}
+		live = stillLive
+		for _, loc := range mine {
+			if claimed[loc] {
+				continue
+			}
+			ant := &replayAnt{loc: loc, row: t.Row(loc), col: t.Col(loc), start: turn.Turn}
+			all = append(all, ant)
+			live = append(live, ant)
+		}
+
+		// Apply the orders
+		byLoc := make(map[Location]*replayAnt)
+		for _, ant := range live {
+			ant.next = ant.loc
+			ant.move = '-'
+			byLoc[ant.loc] = ant
+		}
+		for _, order := range turn.Orders {
+			words := strings.Fields(order)
+			if len(words) != 4 || words[0] != "o" || len(words[3]) != 1 {
+				continue
+			}
+			row, _ := strconv.Atoi(words[1])
+			col, _ := strconv.Atoi(words[2])
+			if row < 0 || row >= t.Rows || col < 0 || col >= t.Cols {
+				continue
+			}
+			dir := words[3][0]
+			if ant := byLoc[t.Loc(row, col)]; ant != nil && ant.move == '-' && strings.Index("NESW", string(dir)) >= 0 {
+				ant.next = t.NewLoc(ant.loc, Direction(dir))
+				ant.move = dir - 'A' + 'a'
+			}
+		}
+		for _, ant := range live {
+			ant.moves = append(ant.moves, ant.move)
+		}
+	}
+	for _, ant := range live {
+		ant.end = endTurn
+	}
+	for _, ant := range all {
+		d.Ants = append(d.Ants, []interface{}{ant.row, ant.col, ant.start, ant.start, ant.end, Me, string(ant.moves)})
+	}
+	for _, loc := range foodLocs {
+		d.Food = append(d.Food, []int{t.Row(loc), t.Col(loc), foodStart[loc] - 1, endTurn})
+	}
+}
+
+func (r *Replay) Write(w io.Writer) os.Error {
+	r.fill()
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (r *Replay) WriteFile(fileName string) os.Error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.Write(f)
+}
+
+func ReadReplay(r io.Reader) (*Replay, os.Error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	res := new(Replay)
+	if err = json.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Stream returns the input of the recorded game as the engine has sent it.
+func (r *Replay) Stream() io.Reader {
+	var buf bytes.Buffer
+	for _, line := range r.ReplayData.BotSetup {
+		buf.WriteString(line + "\n")
+	}
+	for _, turn := range r.ReplayData.BotTurns {
+		for _, line := range turn.Input {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("go\n")
+	}
+	if len(r.ReplayData.BotEnd) > 0 {
+		buf.WriteString("end\n")
+		for _, line := range r.ReplayData.BotEnd {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("go\n")
+	}
+	return &buf
+}
+
+// Diverge returns the index of the first turn where the orders of r and other differ.
+// The order of orders within a turn is not important.
+func (r *Replay) Diverge(other *Replay) (ind int, found bool) {
+	a := r.ReplayData.BotTurns
+	b := other.ReplayData.BotTurns
+	for ind = 0; ind < len(a) && ind < len(b); ind++ {
+		if a[ind].Turn != b[ind].Turn || !sameOrders(a[ind].Orders, b[ind].Orders) {
+			return ind, true
+		}
+	}
+	if len(a) != len(b) {
+		return ind, true
+	}
+	return 0, false
+}
+
+func sameOrders(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	count := make(map[string]int)
+	for _, order := range a {
+		count[order]++
+	}
+	for _, order := range b {
+		if count[order] == 0 {
+			return false
+		}
+		count[order]--
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const replayTestStream = `turn 0
+players 2
+some_future_param 7
+loadtime 3000
+turntime 1000
+rows 10
+cols 10
+turns 3
+viewradius2 55
+attackradius2 5
+spawnradius2 1
+player_seed 42
+ready
+turn 1
+w 1 2
+a 5 5 0
+a 5 6 0
+h 5 5 0
+go
+turn 2
+a 4 5 0
+a 6 6 0
+a 5 5 0
+go
+turn 3
+a 3 5 0
+go
+end
+players 2
+score 1 0
+a 3 5 0
+go
+`
+
+func recordRandomBot(t *testing.T, stream string) *Replay {
+	c := NewConn(strings.NewReader(stream), new(bytes.Buffer))
+	c.Replay = NewReplay()
+	p, err := c.ReadParams()
+	if err != nil {
+		t.Fatalf("ReadParams: %v", err)
+	}
+	if p.Players != 2 || p.Rows != 10 {
+		t.Errorf("Unexpected params: %+v", p)
+	}
+	b := &resultBot{Bot: new(RandomBot)}
+	b.Init(p)
+	if err = c.Loop(p, b); err != nil {
+		t.Fatalf("Loop: %v", err)
+	}
+	if b.res == nil || b.res.Turn != 3 || len(b.res.Scores) != 2 || len(b.res.Input) != 1 {
+		t.Errorf("Unexpected game result: %+v", b.res)
+	}
+	return c.Replay
+}
+
+// resultBot remembers the game result
+type resultBot struct {
+	Bot
+	res *GameResult
+}
+
+func (b *resultBot) End(result *GameResult) {
+	b.res = result
+}
+
+func TestReplay(t *testing.T) {
+	want := recordRandomBot(t, replayTestStream)
+	if len(want.ReplayData.BotTurns) != 3 {
+		t.Fatalf("Turns recorded: %d, want: 3", len(want.ReplayData.BotTurns))
+	}
+	var buf bytes.Buffer
+	if err := want.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	loaded, err := ReadReplay(&buf)
+	if err != nil {
+		t.Fatalf("ReadReplay: %v", err)
+	}
+	if loaded.ReplayData.Turns != 3 || loaded.ReplayData.Players != 2 ||
+		len(loaded.ReplayData.Scores) != 2 || loaded.ReplayData.Map.Data[1] != "..%......." {
+		t.Errorf("Unexpected replay data: %+v", loaded.ReplayData)
+	}
+
+	var stream bytes.Buffer
+	stream.ReadFrom(loaded.Stream())
+	got := recordRandomBot(t, stream.String())
+	if ind, found := got.Diverge(loaded); found {
+		t.Errorf("Same bot diverges at turn #%d: %v, want: %v", ind,
+			got.ReplayData.BotTurns[ind].Orders, loaded.ReplayData.BotTurns[ind].Orders)
+	}
+
+	got.ReplayData.BotTurns[1].Orders = append(got.ReplayData.BotTurns[1].Orders, "o 0 0 N")
+	if ind, found := got.Diverge(loaded); !found || ind != 1 {
+		t.Errorf("Diverge: %d, %v, want: 1, true", ind, found)
+	}
+}
+
+func TestReplayAnts(t *testing.T) {
+	r := NewReplay()
+	r.ReplayData.BotSetup = []string{"rows 10", "cols 10", "ready"}
+	r.AddTurn(1, []string{"a 5 5 0", "f 1 1"}, []string{"o 5 5 E"}, 0, nil)
+	// The second ant is born, the first one is ordered to go south
+	r.AddTurn(2, []string{"a 5 6 0", "a 5 5 0", "f 1 1"}, []string{"o 5 6 S", "o 5 5 N"}, 0, nil)
+	// The first ant has failed to move, the second one is dead, the food is eaten
+	r.AddTurn(3, []string{"a 5 6 0"}, nil, 0, nil)
+	r.fill()
+	if got, want := fmt.Sprint(r.ReplayData.Ants), "[[5 5 1 1 4 0 e--] [5 5 2 2 3 0 n]]"; got != want {
+		t.Errorf("ants: %s, want: %s", got, want)
+	}
+	if got, want := fmt.Sprint(r.ReplayData.Food), "[[1 1 1 3]]"; got != want {
+		t.Errorf("food: %s, want: %s", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+var rerunInput = flag.String("rerun", "", "feed a recorded replay or a captured input stream to the bot instead of stdin")
+var rerunBaseline = flag.String("baseline", "", "replay with the orders to compare against; the -rerun replay is used if empty")
+
+func readReplayFile(fileName string) (*Replay, os.Error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadReplay(f)
+}
+
+// Rerun plays the recorded input with a new bot and compares the orders
+// against the baseline turn by turn. It returns the replay of the new run.
+func Rerun(b Bot, inputName, baselineName string) (got *Replay, err os.Error) {
+	data, err := ioutil.ReadFile(inputName)
+	if err != nil {
+		return
+	}
+	var input io.Reader = bytes.NewBuffer(data)
+	var baseline *Replay
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		// This is a replay, not a captured stream
+		if baseline, err = ReadReplay(input); err != nil {
+			return nil, fmt.Errorf("ReadReplay(%s): %v", inputName, err)
+		}
+		input = baseline.Stream()
+	}
+	if baselineName != "" {
+		if baseline, err = readReplayFile(baselineName); err != nil {
+			return nil, fmt.Errorf("ReadReplay(%s): %v", baselineName, err)
+		}
+	}
+
+	c := NewConn(input, ioutil.Discard)
+	c.Replay = NewReplay()
+	p, err := c.ReadParams()
+	if err != nil {
+		return nil, fmt.Errorf("ReadParams: %v", err)
+	}
+	if err = b.Init(p); err != nil {
+		return nil, fmt.Errorf("bot.Init: %v", err)
+	}
+	if err = c.Loop(p, b); err != nil {
+		return c.Replay, fmt.Errorf("Loop: %v", err)
+	}
+	got = c.Replay
+	if baseline == nil {
+		fmt.Fprintf(os.Stderr, "rerun: %d turns played, no baseline to compare with\n", len(got.ReplayData.BotTurns))
+		return
+	}
+	ind, found := got.Diverge(baseline)
+	if !found {
+		fmt.Fprintf(os.Stderr, "rerun: all %d turns match the baseline\n", len(got.ReplayData.BotTurns))
+		return
+	}
+	gotTurns := got.ReplayData.BotTurns
+	wantTurns := baseline.ReplayData.BotTurns
+	if ind >= len(gotTurns) || ind >= len(wantTurns) {
+		return got, fmt.Errorf("rerun: %d turns played, baseline has %d turns", len(gotTurns), len(wantTurns))
+	}
+	return got, fmt.Errorf("rerun: orders diverge at turn %d:\ngot:  %v\nwant: %v",
+		gotTurns[ind].Turn, gotTurns[ind].Orders, wantTurns[ind].Orders)
+}
@@ -17,6 +17,20 @@ type Locator interface {
 	Dist(from, to Location) int
 }
 
+// BlockingLocator can forget the cells which turn out to be blocked
+// and learn them again when they are free.
+type BlockingLocator interface {
+	Remove(locs ...Location) os.Error
+	Restore(locs ...Location)
+}
+
+// DynamicLocator learns the map as cells are discovered.
+type DynamicLocator interface {
+	Locator
+	Add(locs ...Location) os.Error
+	Update(ok func() bool)
+}
+
 type LocatedSet interface {
 	All() []Location
 	FindNear(loc Location, score int, ok func(worker Location, score int, sameProv bool) bool) (Location, bool)
@@ -148,3 +162,25 @@ func NewGreedyPlanner(size int) Planner {
 		assignedWorkersToTargets: NewLocLocMap(size),
 	}
 }
+
+// NewPlanner returns the planner by its name: "greedy" or "matching".
+func NewPlanner(name string, size int) (Planner, os.Error) {
+	switch name {
+	case "greedy":
+		return NewGreedyPlanner(size), nil
+	case "matching":
+		return NewMatchingPlanner(size), nil
+	}
+	return nil, fmt.Errorf("unknown planner: %s", name)
+}
+
+// NewLocator returns the locator by its name: "fair" or "landmark".
+func NewLocator(name string, conn Connector, size int) (DynamicLocator, os.Error) {
+	switch name {
+	case "fair":
+		return NewFairLocator(conn, size), nil
+	case "landmark":
+		return NewLandmarkLocator(conn, size), nil
+	}
+	return nil, fmt.Errorf("unknown locator: %s", name)
+}
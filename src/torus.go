@@ -74,3 +74,22 @@ func (t Torus) Neighbours(loc Location) []Location {
 func (t Torus) Size() int {
 	return t.Rows * t.Cols
 }
+
+// Dist2 returns the squared euclidean distance between the locations.
+func (t Torus) Dist2(a, b Location) int {
+	dr := t.Row(a) - t.Row(b)
+	if dr < 0 {
+		dr = -dr
+	}
+	if t.Rows-dr < dr {
+		dr = t.Rows - dr
+	}
+	dc := t.Col(a) - t.Col(b)
+	if dc < 0 {
+		dc = -dc
+	}
+	if t.Cols-dc < dc {
+		dc = t.Cols - dc
+	}
+	return dr*dr + dc*dc
+}
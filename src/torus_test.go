@@ -32,3 +32,27 @@ func TestGuessDir(t *testing.T) {
 		}
 	}
 }
+
+type Dist2Test struct {
+	T          Torus
+	ARow, ACol int
+	BRow, BCol int
+	Dist2      int
+}
+
+var dist2Tests = []Dist2Test{
+	{t4, 1, 1, 1, 1, 0},
+	{t4, 1, 1, 2, 2, 2},
+	{t4, 0, 0, 3, 3, 2},
+	{t32, 0, 0, 30, 5, 29},
+}
+
+func TestDist2(t *testing.T) {
+	for _, test := range dist2Tests {
+		a := test.T.Loc(test.ARow, test.ACol)
+		b := test.T.Loc(test.BRow, test.BCol)
+		if d := test.T.Dist2(a, b); d != test.Dist2 {
+			t.Errorf("a: %v, b: %v, want: %d, got: %d", a, b, test.Dist2, d)
+		}
+	}
+}